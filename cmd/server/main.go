@@ -1,15 +1,64 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"game-server-v1/pkg/game"
 	"game-server-v1/pkg/network"
+	"game-server-v1/pkg/types"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
+	configPath := flag.String("config", "", "path to a JSON game config file")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	udpAddr := flag.String("udp", ":8081", "UDP address for native clients; empty disables UDP")
+	adminToken := flag.String("admin-token", os.Getenv("GAME_ADMIN_TOKEN"),
+		"bearer token required to create and destroy rooms, defaulting to $GAME_ADMIN_TOKEN; empty disables room management")
+	flag.Parse()
 
-	hub := game.NewGameHub(nil)
+	config := types.GetDefaultConfig()
+	if *configPath != "" {
+		var err error
+		if config, err = types.LoadGameConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	hub.Start()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	network.HandleSocket(hub)
+	rooms := game.NewRoomManager(ctx, config)
+
+	server := network.NewServer(rooms, *addr, *adminToken)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	if *udpAddr != "" {
+		go func() {
+			if err := server.ServeUDP(*udpAddr); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down, draining connections...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), types.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown incomplete: %v", err)
+		return
+	}
+	log.Println("Server stopped")
 }
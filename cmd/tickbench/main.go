@@ -0,0 +1,129 @@
+// Command tickbench measures GameHub tick time under a synthetic load of
+// in-process players and projectiles.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/types"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	players := flag.Int("players", types.DefaultMaxPlayers, "number of simulated players")
+	projectiles := flag.Int("projectiles", 1000, "number of projectiles to keep in flight")
+	duration := flag.Duration("duration", 10*time.Second, "measurement duration")
+	mapFile := flag.String("map", "", "optional JSON map file")
+	verbose := flag.Bool("v", false, "keep hub logging enabled")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	config := types.GetDefaultConfig()
+	config.MapFile = *mapFile
+
+	// A weapon without cooldown or ammo so the projectile count is only limited by the driver
+	config.Weapons["bench"] = types.WeaponConfig{
+		ProjectileSpeed: 5,
+		Radius:          0.25,
+		Lifetime:        5 * time.Second,
+	}
+	config.DefaultWeapon = "bench"
+
+	hub := game.NewGameHub(config)
+	hub.Start(context.Background())
+	defer hub.Stop()
+
+	clients := make([]*types.Client, *players)
+	for i := range clients {
+		client := &types.Client{
+			UUID:     uuid.New().String(),
+			Send:     make(chan []byte, 256),
+			Snapshot: make(chan []byte, 1),
+			LastSeen: time.Now(),
+		}
+		clients[i] = client
+
+		// Drain outgoing messages like a WritePump would
+		go func() {
+			for {
+				select {
+				case _, ok := <-client.Send:
+					if !ok {
+						return
+					}
+				case <-client.Snapshot:
+				}
+			}
+		}()
+
+		hub.GetRegisterChan() <- client
+	}
+
+	// Random walk for every player, one input per tick
+	go func() {
+		ticker := time.NewTicker(config.TickInterval)
+		defer ticker.Stop()
+		seq := int64(0)
+		for range ticker.C {
+			seq++
+			for _, client := range clients {
+				hub.GetPlayerInputChan() <- &types.PlayerInputMessage{
+					Type:       string(types.PlayerInputMsg),
+					PlayerID:   client.UUID,
+					MoveX:      rand.Float64()*2 - 1,
+					MoveY:      rand.Float64()*2 - 1,
+					SequenceID: seq,
+				}
+			}
+		}
+	}()
+
+	// Top up projectiles towards the target count
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			deficit := *projectiles - len(hub.GetGameState().Projectiles)
+			for i := 0; i < min(deficit, 200); i++ {
+				angle := rand.Float64() * 2 * math.Pi
+				hub.Shoot(clients[rand.Intn(len(clients))], &types.ShootMessage{
+					Type: string(types.ShootMsg),
+					DirX: math.Cos(angle),
+					DirY: math.Sin(angle),
+				})
+			}
+		}
+	}()
+
+	fmt.Printf("warming up with %d players and %d projectiles...\n", *players, *projectiles)
+	time.Sleep(2 * time.Second)
+
+	var simTotal, tickTotal time.Duration
+	samples := 0
+	end := time.Now().Add(*duration)
+	for time.Now().Before(end) {
+		time.Sleep(time.Second)
+		stats := hub.GetStats()
+		state := hub.GetGameState()
+		simTotal += stats.AvgSimTime
+		tickTotal += stats.AvgTickTime
+		samples++
+		fmt.Printf("players=%d projectiles=%d sim=%v tick=%v\n",
+			len(state.Players), len(state.Projectiles), stats.AvgSimTime, stats.AvgTickTime)
+	}
+
+	stats := hub.GetStats()
+	fmt.Printf("\naverage sim=%v tick=%v max tick=%v (budget %v)\n",
+		simTotal/time.Duration(samples), tickTotal/time.Duration(samples), stats.MaxTickTime, config.TickInterval)
+}
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"log"
+	"time"
+)
+
+// queueSnapshot hands a client its snapshot for this tick, replacing any it has not
+// been sent yet. A client lags while every new snapshot finds the previous one still
+// waiting; once that has lasted MaxClientLag it is disconnected.
+func (h *GameHub) queueSnapshot(client *types.Client, view *clientView, data []byte, now time.Time) {
+	if !client.QueueSnapshot(data) {
+		view.laggingSince = time.Time{}
+		return
+	}
+
+	h.stats.mu.Lock()
+	h.stats.ConflatedSnapshots++
+	h.stats.mu.Unlock()
+
+	if view.laggingSince.IsZero() {
+		view.laggingSince = now
+		return
+	}
+	if h.config.MaxClientLag > 0 && now.Sub(view.laggingSince) > h.config.MaxClientLag && !view.kicked {
+		log.Printf("Client %s lagged for %v, disconnecting", client.UUID, now.Sub(view.laggingSince))
+		view.kicked = true
+		client.CloseCode = types.ClosePolicyViolation
+		client.CloseReason = "client cannot keep up"
+		go h.Unregister(client)
+	}
+}
+
+// recordBacklog publishes how far behind the clients are after a broadcast
+func (h *GameHub) recordBacklog() {
+	lagging, maxBacklog := 0, 0
+
+	for client, view := range h.views {
+		if !view.laggingSince.IsZero() {
+			lagging++
+		}
+		maxBacklog = max(maxBacklog, len(client.Send))
+	}
+
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	h.stats.LaggingClients = lagging
+	h.stats.MaxSendBacklog = maxBacklog
+}
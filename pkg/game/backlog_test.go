@@ -0,0 +1,81 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"testing"
+	"time"
+)
+
+// emptySnapshotSlot drops the snapshot a client was sent on joining
+func emptySnapshotSlot(client *types.Client) {
+	for len(client.Snapshot) > 0 {
+		<-client.Snapshot
+	}
+}
+
+func TestSnapshotsConflateForSlowClients(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON)
+	view := h.views[client]
+	emptySnapshotSlot(client)
+	now := time.Now()
+
+	h.queueSnapshot(client, view, []byte("tick 1"), now)
+	h.queueSnapshot(client, view, []byte("tick 2"), now)
+	h.queueSnapshot(client, view, []byte("tick 3"), now)
+
+	// Only the newest unsent snapshot is kept
+	if len(client.Snapshot) != 1 || string(<-client.Snapshot) != "tick 3" {
+		t.Fatal("the slot does not hold just the newest snapshot")
+	}
+	if got := h.GetStats().ConflatedSnapshots; got != 2 {
+		t.Errorf("ConflatedSnapshots = %d, want 2", got)
+	}
+	if view.laggingSince != now {
+		t.Errorf("laggingSince = %v, want the first conflation at %v", view.laggingSince, now)
+	}
+
+	// Once the client takes a snapshot in time it is no longer lagging
+	h.queueSnapshot(client, view, []byte("tick 4"), now.Add(time.Second))
+	if !view.laggingSince.IsZero() {
+		t.Error("still lagging after keeping up")
+	}
+}
+
+func TestSustainedLagDisconnects(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.MaxClientLag = time.Second
+	h := newTestHub(t, config)
+	client := addClient(h, "p1", types.EncodingJSON)
+	view := h.views[client]
+	emptySnapshotSlot(client)
+	start := time.Now()
+
+	h.queueSnapshot(client, view, []byte("tick"), start)
+	for _, after := range []time.Duration{0, 500 * time.Millisecond, time.Second} {
+		h.queueSnapshot(client, view, []byte("tick"), start.Add(after))
+	}
+	if view.kicked || client.CloseCode != 0 {
+		t.Fatal("disconnected before lagging for longer than MaxClientLag")
+	}
+
+	h.queueSnapshot(client, view, []byte("tick"), start.Add(1500*time.Millisecond))
+	h.queueSnapshot(client, view, []byte("tick"), start.Add(2*time.Second))
+	if client.CloseCode != types.ClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", client.CloseCode, types.ClosePolicyViolation)
+	}
+
+	select {
+	case c := <-h.unregister:
+		if c != client {
+			t.Errorf("unregistered %s, want the lagging client", c.UUID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lagging client was not unregistered")
+	}
+	select {
+	case <-h.unregister:
+		t.Error("lagging client unregistered twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
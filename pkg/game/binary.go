@@ -0,0 +1,225 @@
+package game
+
+import (
+	"game-server-v1/pkg/protocol"
+	"game-server-v1/pkg/types"
+	"time"
+)
+
+// Field bits of binary player deltas
+const (
+	playerFieldPosX uint64 = 1 << iota
+	playerFieldPosY
+	playerFieldMoveX
+	playerFieldMoveY
+	playerFieldFlags
+	playerFieldHealth
+	playerFieldWeapon
+	playerFieldAmmo
+	playerFieldLastInput
+)
+
+// Field bits of binary projectile deltas
+const (
+	projectileFieldOwner uint64 = 1 << iota
+	projectileFieldPosX
+	projectileFieldPosY
+	projectileFieldVelX
+	projectileFieldVelY
+	projectileFieldRadius
+)
+
+// writeSnapshotHeader writes tick metadata shared by full and delta snapshots:
+// tick uvarint | serverTime uvarint (ms) | tickInterval uvarint (µs)
+func (h *GameHub) writeSnapshotHeader(w *protocol.Writer, tick uint64) {
+	w.Uvarint(tick)
+	w.Uvarint(uint64(time.Now().UnixMilli()))
+	w.Uvarint(uint64(h.config.TickInterval.Microseconds()))
+}
+
+// playerFlags packs a player's booleans into one byte
+func playerFlags(p *types.PlayerSnapshot) byte {
+	var flags byte
+	if p.FacingLeft {
+		flags |= protocol.FlagFacingLeft
+	}
+	if p.IsAlive {
+		flags |= protocol.FlagAlive
+	}
+	if p.Invulnerable {
+		flags |= protocol.FlagInvulnerable
+	}
+	if p.Reloading {
+		flags |= protocol.FlagReloading
+	}
+	return flags
+}
+
+// writePlayerBinary writes a player in full:
+// netId, posX, posY, moveX, moveY, flags, health, weapon, ammo, lastInput
+func writePlayerBinary(w *protocol.Writer, p *types.PlayerSnapshot) {
+	w.Uvarint(uint64(p.NetID))
+	w.Fixed(p.PosX, protocol.PositionScale)
+	w.Fixed(p.PosY, protocol.PositionScale)
+	w.Fixed(p.MoveX, protocol.InputScale)
+	w.Fixed(p.MoveY, protocol.InputScale)
+	w.Byte(playerFlags(p))
+	w.Varint(int64(p.Health))
+	w.String(p.Weapon)
+	w.Varint(int64(p.Ammo))
+	w.Varint(p.LastProcessedInput)
+}
+
+// writeProjectileBinary writes a projectile in full:
+// netId, ownerNetId, posX, posY, velX, velY, radius
+func writeProjectileBinary(w *protocol.Writer, proj *types.ProjectileSnapshot) {
+	w.Uvarint(uint64(proj.NetID))
+	w.Uvarint(uint64(proj.OwnerNetID))
+	w.Fixed(proj.PosX, protocol.PositionScale)
+	w.Fixed(proj.PosY, protocol.PositionScale)
+	w.Fixed(proj.VelX, protocol.PositionScale)
+	w.Fixed(proj.VelY, protocol.PositionScale)
+	w.Fixed(proj.Radius, protocol.PositionScale)
+}
+
+// playerMask returns the field bits of cur that differ from old, every bit when old is nil
+func playerMask(old, cur *types.PlayerSnapshot) uint64 {
+	if old == nil {
+		return ^uint64(0)
+	}
+
+	var mask uint64
+	if old.PosX != cur.PosX {
+		mask |= playerFieldPosX
+	}
+	if old.PosY != cur.PosY {
+		mask |= playerFieldPosY
+	}
+	if old.MoveX != cur.MoveX {
+		mask |= playerFieldMoveX
+	}
+	if old.MoveY != cur.MoveY {
+		mask |= playerFieldMoveY
+	}
+	if playerFlags(old) != playerFlags(cur) {
+		mask |= playerFieldFlags
+	}
+	if old.Health != cur.Health {
+		mask |= playerFieldHealth
+	}
+	if old.Weapon != cur.Weapon {
+		mask |= playerFieldWeapon
+	}
+	if old.Ammo != cur.Ammo {
+		mask |= playerFieldAmmo
+	}
+	if old.LastProcessedInput != cur.LastProcessedInput {
+		mask |= playerFieldLastInput
+	}
+	return mask
+}
+
+// projectileMask returns the field bits of cur that differ from old, every bit when old is nil
+func projectileMask(old, cur *types.ProjectileSnapshot) uint64 {
+	if old == nil {
+		return ^uint64(0)
+	}
+
+	var mask uint64
+	if old.OwnerNetID != cur.OwnerNetID {
+		mask |= projectileFieldOwner
+	}
+	if old.PosX != cur.PosX {
+		mask |= projectileFieldPosX
+	}
+	if old.PosY != cur.PosY {
+		mask |= projectileFieldPosY
+	}
+	if old.VelX != cur.VelX {
+		mask |= projectileFieldVelX
+	}
+	if old.VelY != cur.VelY {
+		mask |= projectileFieldVelY
+	}
+	if old.Radius != cur.Radius {
+		mask |= projectileFieldRadius
+	}
+	return mask
+}
+
+// writePlayerDeltaBinary writes a changed player: netId, field mask, present fields
+func writePlayerDeltaBinary(w *protocol.Writer, p *types.PlayerSnapshot, mask uint64) {
+	w.Uvarint(uint64(p.NetID))
+	w.Uvarint(mask & (playerFieldLastInput<<1 - 1))
+	if mask&playerFieldPosX != 0 {
+		w.Fixed(p.PosX, protocol.PositionScale)
+	}
+	if mask&playerFieldPosY != 0 {
+		w.Fixed(p.PosY, protocol.PositionScale)
+	}
+	if mask&playerFieldMoveX != 0 {
+		w.Fixed(p.MoveX, protocol.InputScale)
+	}
+	if mask&playerFieldMoveY != 0 {
+		w.Fixed(p.MoveY, protocol.InputScale)
+	}
+	if mask&playerFieldFlags != 0 {
+		w.Byte(playerFlags(p))
+	}
+	if mask&playerFieldHealth != 0 {
+		w.Varint(int64(p.Health))
+	}
+	if mask&playerFieldWeapon != 0 {
+		w.String(p.Weapon)
+	}
+	if mask&playerFieldAmmo != 0 {
+		w.Varint(int64(p.Ammo))
+	}
+	if mask&playerFieldLastInput != 0 {
+		w.Varint(p.LastProcessedInput)
+	}
+}
+
+// writeProjectileDeltaBinary writes a changed projectile: netId, field mask, present fields
+func writeProjectileDeltaBinary(w *protocol.Writer, proj *types.ProjectileSnapshot, mask uint64) {
+	w.Uvarint(uint64(proj.NetID))
+	w.Uvarint(mask & (projectileFieldRadius<<1 - 1))
+	if mask&projectileFieldOwner != 0 {
+		w.Uvarint(uint64(proj.OwnerNetID))
+	}
+	if mask&projectileFieldPosX != 0 {
+		w.Fixed(proj.PosX, protocol.PositionScale)
+	}
+	if mask&projectileFieldPosY != 0 {
+		w.Fixed(proj.PosY, protocol.PositionScale)
+	}
+	if mask&projectileFieldVelX != 0 {
+		w.Fixed(proj.VelX, protocol.PositionScale)
+	}
+	if mask&projectileFieldVelY != 0 {
+		w.Fixed(proj.VelY, protocol.PositionScale)
+	}
+	if mask&projectileFieldRadius != 0 {
+		w.Fixed(proj.Radius, protocol.PositionScale)
+	}
+}
+
+// encodeHitBinary writes a playerDamaged frame:
+// victim netId | attacker netId | projectile netId | damage | health
+func encodeHitBinary(hit projectileHit) []byte {
+	w := protocol.NewWriter(protocol.OpPlayerDamaged)
+	w.Uvarint(uint64(hit.VictimNetID))
+	w.Uvarint(uint64(hit.AttackerNetID))
+	w.Uvarint(uint64(hit.ProjectileNetID))
+	w.Varint(int64(hit.Damage))
+	w.Varint(int64(hit.Health))
+	return w.Bytes()
+}
+
+// encodeKillBinary writes a playerKilled frame: victim netId | killer netId
+func encodeKillBinary(hit projectileHit) []byte {
+	w := protocol.NewWriter(protocol.OpPlayerKilled)
+	w.Uvarint(uint64(hit.VictimNetID))
+	w.Uvarint(uint64(hit.AttackerNetID))
+	return w.Bytes()
+}
@@ -0,0 +1,216 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"math"
+	"slices"
+)
+
+// Snapshot packing weights
+const (
+	priorityFalloff        = 10.0 // distance in world units at which an entity's priority halves
+	priorityNewEntity      = 2.0  // entities the client has not been sent yet
+	priorityAlwaysRelevant = 2.0
+	priorityOwnProjectile  = 4.0
+
+	// budgetBurstTicks is how many ticks of unused budget a client may save up
+	budgetBurstTicks = 4
+
+	// entityCostWeight is how fast the per-entity byte estimate follows measured snapshots
+	entityCostWeight = 0.2
+)
+
+// initialEntityCost guesses the bytes one changed entity adds to a snapshot until measured
+var initialEntityCost = map[string]float64{
+	types.EncodingJSON:   160,
+	types.EncodingBinary: 20,
+}
+
+// entityKey identifies a replicated entity of either kind
+type entityKey struct {
+	kind EntityKind
+	id   string
+}
+
+// packCandidate is a changed entity competing for room in a snapshot
+type packCandidate struct {
+	key      entityKey
+	priority float64
+}
+
+// refillBudget adds one tick's worth of bandwidth to a client's budget, whether or
+// not it is sent a snapshot this tick
+func (h *GameHub) refillBudget(client *types.Client, view *clientView) {
+	if client.Bandwidth <= 0 {
+		return
+	}
+	perTick := float64(client.Bandwidth) * h.config.TickInterval.Seconds()
+	view.budget = math.Min(view.budget+perTick, perTick*budgetBurstTicks)
+}
+
+// packSnapshot fits a client's visible snapshot into its bandwidth budget. It returns
+// the snapshot to send, how many changed entities it carries and whether to send one
+// this tick at all.
+//
+// Against a baseline, changed entities are sent in order of accumulated priority
+// until the budget runs out. Skipped entities the client already has keep their
+// baseline state, so the delta leaves them untouched and the remembered snapshot
+// stays an exact record of what the client holds; skipped new entities wait. Their
+// priority keeps growing until they make it in. Clients without delta snapshots
+// replace their whole view with each snapshot, so they skip ticks instead.
+//
+// Caller must hold the GameState read lock.
+func (h *GameHub) packSnapshot(client *types.Client, view *clientView, visible *snapshot) (*snapshot, int, bool) {
+	entities := len(visible.players) + len(visible.projectiles)
+	if client.Bandwidth <= 0 {
+		return visible, entities, true
+	}
+
+	base := view.baseline(visible.tick, h.config.SnapshotHistory)
+	if base == nil && !client.HasFeature(types.FeatureDeltaSnapshots) {
+		return visible, entities, view.budget >= 0
+	}
+	if base == nil {
+		// A full snapshot; nothing is known to the client yet
+		base = &snapshot{}
+	}
+
+	if view.entityCost == 0 {
+		view.entityCost = initialEntityCost[client.Encoding]
+	}
+
+	viewer, hasViewer := visible.players[client.UUID]
+	packed := &snapshot{
+		tick:        visible.tick,
+		time:        visible.time,
+		players:     make(map[string]types.PlayerSnapshot, len(visible.players)),
+		projectiles: make(map[string]types.ProjectileSnapshot, len(visible.projectiles)),
+	}
+	var candidates []packCandidate
+	sent := 0
+
+	score := func(x, y, change, weight float64, known bool) float64 {
+		s := weight * (1 + change)
+		if !known {
+			s *= priorityNewEntity
+		}
+		if hasViewer {
+			s /= 1 + math.Hypot(x-viewer.PosX, y-viewer.PosY)/priorityFalloff
+		}
+		return s
+	}
+
+	for id, cur := range visible.players {
+		old, known := base.players[id]
+		switch {
+		case id == client.UUID:
+			// The viewer's own player drives prediction reconciliation and always goes out
+			packed.players[id] = cur
+			sent++
+		case known && old == cur:
+			packed.players[id] = cur
+			delete(view.priority, entityKey{kind: EntityPlayer, id: id})
+		default:
+			weight := 1.0
+			if h.state.alwaysRelevant[id] {
+				weight = priorityAlwaysRelevant
+			}
+			change := 0.0
+			if known {
+				change = math.Hypot(cur.PosX-old.PosX, cur.PosY-old.PosY)
+				if old.Health != cur.Health || old.IsAlive != cur.IsAlive || old.Weapon != cur.Weapon {
+					change++
+				}
+			}
+			key := entityKey{kind: EntityPlayer, id: id}
+			view.priority[key] += score(cur.PosX, cur.PosY, change, weight, known)
+			candidates = append(candidates, packCandidate{key: key, priority: view.priority[key]})
+		}
+	}
+
+	for id, cur := range visible.projectiles {
+		old, known := base.projectiles[id]
+		if known && old == cur {
+			packed.projectiles[id] = cur
+			delete(view.priority, entityKey{kind: EntityProjectile, id: id})
+			continue
+		}
+		weight := 1.0
+		if cur.OwnerID == client.UUID {
+			weight = priorityOwnProjectile
+		}
+		change := 0.0
+		if known {
+			change = math.Hypot(cur.PosX-old.PosX, cur.PosY-old.PosY)
+		}
+		key := entityKey{kind: EntityProjectile, id: id}
+		view.priority[key] += score(cur.PosX, cur.PosY, change, weight, known)
+		candidates = append(candidates, packCandidate{key: key, priority: view.priority[key]})
+	}
+
+	slices.SortFunc(candidates, func(a, b packCandidate) int {
+		if a.priority > b.priority {
+			return -1
+		}
+		if a.priority < b.priority {
+			return 1
+		}
+		return 0
+	})
+
+	remaining := view.budget - float64(sent)*view.entityCost
+	skipped := 0
+	for _, c := range candidates {
+		if remaining >= view.entityCost {
+			remaining -= view.entityCost
+			delete(view.priority, c.key)
+			sent++
+			if c.key.kind == EntityPlayer {
+				packed.players[c.key.id] = visible.players[c.key.id]
+			} else {
+				packed.projectiles[c.key.id] = visible.projectiles[c.key.id]
+			}
+			continue
+		}
+
+		skipped++
+		if c.key.kind == EntityPlayer {
+			if old, ok := base.players[c.key.id]; ok {
+				packed.players[c.key.id] = old
+			}
+		} else if old, ok := base.projectiles[c.key.id]; ok {
+			packed.projectiles[c.key.id] = old
+		}
+	}
+
+	// Forget the priority of entities that left the client's view
+	for key := range view.priority {
+		var ok bool
+		if key.kind == EntityPlayer {
+			_, ok = visible.players[key.id]
+		} else {
+			_, ok = visible.projectiles[key.id]
+		}
+		if !ok {
+			delete(view.priority, key)
+		}
+	}
+
+	// Everything fit, so the shared encoding can be reused
+	if skipped == 0 {
+		return visible, sent, true
+	}
+	return packed, sent, true
+}
+
+// spendBudget charges an encoded snapshot to the client's budget and refines the
+// estimate of what one changed entity costs
+func (h *GameHub) spendBudget(client *types.Client, view *clientView, size, entities int) {
+	if client.Bandwidth <= 0 {
+		return
+	}
+	view.budget -= float64(size)
+	if entities > 0 {
+		view.entityCost += entityCostWeight * (float64(size)/float64(entities) - view.entityCost)
+	}
+}
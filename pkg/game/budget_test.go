@@ -0,0 +1,125 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"slices"
+	"testing"
+)
+
+// testEntityCost is the byte estimate of one changed entity in the packing tests
+const testEntityCost = 100
+
+// snapshotOf builds a snapshot at tick from players and projectiles
+func snapshotOf(tick uint64, players []types.PlayerSnapshot, projectiles ...types.ProjectileSnapshot) *snapshot {
+	snap := &snapshot{
+		tick:        tick,
+		players:     make(map[string]types.PlayerSnapshot),
+		projectiles: make(map[string]types.ProjectileSnapshot),
+	}
+	for _, p := range players {
+		snap.players[p.ID] = p
+	}
+	for _, p := range projectiles {
+		snap.projectiles[p.ID] = p
+	}
+	return snap
+}
+
+// newPackingClient returns a delta client named "me" that acknowledged base, and its view
+func newPackingClient(base *snapshot) (*types.Client, *clientView) {
+	client := &types.Client{
+		UUID:      "me",
+		Encoding:  types.EncodingJSON,
+		Bandwidth: 10000,
+		Features:  map[string]bool{types.FeatureDeltaSnapshots: true},
+	}
+	view := newClientView()
+	view.entityCost = testEntityCost
+	view.remember(base, 10)
+	view.acknowledge(base.tick)
+	return client, view
+}
+
+// playerAt is a live player snapshot at x
+func playerAt(id string, x float64) types.PlayerSnapshot {
+	return types.PlayerSnapshot{ID: id, PosX: x, Health: 100, IsAlive: true}
+}
+
+func TestPackSnapshotPriorityAndBudget(t *testing.T) {
+	base := snapshotOf(1, []types.PlayerSnapshot{playerAt("me", 0), playerAt("near", 2), playerAt("far", 40)})
+	visible := snapshotOf(2,
+		[]types.PlayerSnapshot{playerAt("me", 1), playerAt("near", 3), playerAt("far", 41), playerAt("new", 1.5)},
+		types.ProjectileSnapshot{ID: "mine", OwnerID: "me", PosX: 30},
+	)
+
+	tests := []struct {
+		name     string
+		budget   float64 // in entities, the viewer's own player included
+		wantSent []string
+		wantKept []string // skipped but known, so left at their baseline state
+	}{
+		{"everything fits", 5, []string{"me", "mine", "new", "near", "far"}, nil},
+		// Own projectiles and new entities outrank a player that merely moved
+		{"own projectile first", 2, []string{"me", "mine"}, []string{"near", "far"}},
+		{"then new entities", 3, []string{"me", "mine", "new"}, []string{"near", "far"}},
+		{"distant changes last", 4, []string{"me", "mine", "new", "near"}, []string{"far"}},
+		{"the viewer always goes out", 0, []string{"me"}, []string{"near", "far"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(t, nil)
+			client, view := newPackingClient(base)
+			view.budget = tt.budget * testEntityCost
+
+			packed, sent, send := h.packSnapshot(client, view, visible)
+			if !send || sent != len(tt.wantSent) {
+				t.Fatalf("sent %d entities (send=%v), want %v", sent, send, tt.wantSent)
+			}
+			for _, id := range tt.wantSent {
+				p, isPlayer := packed.players[id]
+				proj, isProjectile := packed.projectiles[id]
+				if (!isPlayer || p != visible.players[id]) && (!isProjectile || proj != visible.projectiles[id]) {
+					t.Errorf("%s not sent in its current state", id)
+				}
+			}
+			for _, id := range tt.wantKept {
+				if packed.players[id] != base.players[id] {
+					t.Errorf("skipped %s = %+v, want its baseline %+v", id, packed.players[id], base.players[id])
+				}
+			}
+			// A skipped new entity waits, since the client has no state to keep
+			if _, ok := packed.players["new"]; ok && !slices.Contains(tt.wantSent, "new") {
+				t.Error("a skipped new entity was included")
+			}
+		})
+	}
+}
+
+func TestPackSnapshotSkippedEntitiesCatchUp(t *testing.T) {
+	h := newTestHub(t, nil)
+	base := snapshotOf(1, []types.PlayerSnapshot{playerAt("me", 0), playerAt("near", 2), playerAt("far", 40)})
+	client, view := newPackingClient(base)
+
+	// Room for the viewer and one other each tick; the client never acks, so the
+	// baseline and the changes stay the same
+	for tick := uint64(2); tick < 20; tick++ {
+		visible := snapshotOf(tick, []types.PlayerSnapshot{playerAt("me", 1), playerAt("near", 3), playerAt("far", 41)})
+		view.budget = 2 * testEntityCost
+
+		packed, _, _ := h.packSnapshot(client, view, visible)
+		if packed.players["far"] == visible.players["far"] {
+			if packed.players["near"] != base.players["near"] {
+				t.Error("both players sent over budget")
+			}
+			if view.priority[entityKey{kind: EntityPlayer, id: "far"}] != 0 {
+				t.Error("priority kept after the entity was sent")
+			}
+			return
+		}
+		if view.priority[entityKey{kind: EntityPlayer, id: "far"}] == 0 {
+			t.Fatalf("tick %d: skipped entity lost its priority", tick)
+		}
+	}
+	t.Fatal("distant player starved by a nearer one")
+}
@@ -0,0 +1,222 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// pendingEvent is a sequenced event a client has not acknowledged yet
+type pendingEvent struct {
+	seq    uint64
+	data   []byte // the marshaled EventMessage
+	sentAt time.Time
+}
+
+// eventLog is one player's reliable event stream. It outlives the connection so
+// unacknowledged events can be replayed after a session resume. Only the run loop touches it.
+type eventLog struct {
+	client  *types.Client // nil while the player is disconnected
+	lastSeq uint64
+	pending []*pendingEvent
+}
+
+// openEventLog starts the event stream of a client that negotiated reliable events
+func (h *GameHub) openEventLog(client *types.Client) {
+	if client.HasFeature(types.FeatureReliableEvents) {
+		h.events[client.UUID] = &eventLog{client: client}
+	}
+}
+
+// resumeEventLog attaches a resumed client to its player's event stream and replays
+// everything it has not acknowledged. The stream is dropped if the new connection
+// did not negotiate reliable events, and started afresh if the old one had not.
+func (h *GameHub) resumeEventLog(client *types.Client) {
+	if !client.HasFeature(types.FeatureReliableEvents) {
+		delete(h.events, client.UUID)
+		return
+	}
+
+	events, ok := h.events[client.UUID]
+	if !ok {
+		h.openEventLog(client)
+		return
+	}
+
+	events.client = client
+	now := time.Now()
+	for _, event := range events.pending {
+		event.sentAt = now
+		h.queueEvent(client, event.data)
+	}
+}
+
+// detachEventLog keeps a disconnected player's events until it resumes or is removed
+func (h *GameHub) detachEventLog(playerID string) {
+	if events, ok := h.events[playerID]; ok {
+		events.client = nil
+	}
+}
+
+// publishEvent delivers a gameplay event to every client. Clients with reliable events
+// get it sequenced and retained until acknowledged, including players awaiting
+// reconnection; the rest get it once, best effort, in their negotiated encoding.
+// binary may be nil if the event has no binary form.
+func (h *GameHub) publishEvent(msg interface{}, binary []byte) {
+	event, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling event: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for playerID, events := range h.events {
+		events.lastSeq++
+		data, err := json.Marshal(types.EventMessage{
+			Type:  string(types.EventMsg),
+			Seq:   events.lastSeq,
+			Event: event,
+		})
+		if err != nil {
+			log.Printf("Error marshaling event for player %s: %v", playerID, err)
+			continue
+		}
+
+		events.pending = append(events.pending, &pendingEvent{seq: events.lastSeq, data: data, sentAt: now})
+		if h.config.MaxPendingEvents > 0 && len(events.pending) > h.config.MaxPendingEvents {
+			h.overflowEventLog(playerID, events)
+		}
+		if events.client != nil {
+			h.queueEvent(events.client, data)
+		}
+	}
+
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+
+	for client := range h.clients {
+		if client.HasFeature(types.FeatureReliableEvents) {
+			continue
+		}
+		data := event
+		if binary != nil && client.Encoding == types.EncodingBinary {
+			data = binary
+		}
+		select {
+		case client.Send <- data:
+		default:
+			log.Printf("Client %s buffer full, dropping event", client.UUID)
+		}
+	}
+}
+
+// overflowEventLog drops the oldest event of a stream that grew past MaxPendingEvents.
+// A connected client that stopped acknowledging is disconnected; on resume it sees
+// the gap in sequence numbers and relies on the full resync.
+func (h *GameHub) overflowEventLog(playerID string, events *eventLog) {
+	events.pending[0] = nil
+	events.pending = events.pending[1:]
+
+	if client := events.client; client != nil {
+		log.Printf("Client %s stopped acknowledging events, disconnecting", client.UUID)
+		client.CloseCode = types.ClosePolicyViolation
+		client.CloseReason = "too many unacknowledged events"
+		events.client = nil
+		go h.Unregister(client)
+	}
+}
+
+// acknowledgeEvents forgets every event up to and including seq
+func (h *GameHub) acknowledgeEvents(client *types.Client, seq uint64) {
+	events, ok := h.events[client.UUID]
+	if !ok || events.client != client {
+		return
+	}
+
+	n := 0
+	for n < len(events.pending) && events.pending[n].seq <= seq {
+		events.pending[n] = nil
+		n++
+	}
+	events.pending = events.pending[n:]
+}
+
+// resendEvents sends events again to clients that have not acknowledged them in time
+func (h *GameHub) resendEvents(now time.Time) {
+	if h.config.EventResendInterval <= 0 {
+		return
+	}
+
+	for _, events := range h.events {
+		if events.client == nil {
+			continue
+		}
+		for _, event := range events.pending {
+			if now.Sub(event.sentAt) < h.config.EventResendInterval {
+				continue
+			}
+			event.sentAt = now
+			h.queueEvent(events.client, event.data)
+		}
+	}
+}
+
+// queueEvent hands a sequenced event to a client's WritePump. A full buffer is not
+// fatal since the event is resent until acknowledged.
+func (h *GameHub) queueEvent(client *types.Client, data []byte) {
+	select {
+	case client.Send <- data:
+	default:
+		log.Printf("Client %s buffer full, event will be resent", client.UUID)
+	}
+}
+
+// handleChat validates a chat line and publishes it to everyone
+func (h *GameHub) handleChat(client *types.Client, text string) {
+	// Views exist only for registered clients, whose Send channel is still open
+	if _, ok := h.views[client]; !ok || client.Player == nil {
+		return
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if !utf8.ValidString(text) || utf8.RuneCountInString(text) > h.config.MaxChatLength {
+		h.sendToClient(client, types.ErrorMessage{
+			Type:    string(types.ErrorMsg),
+			Code:    types.ErrorCodeBadRequest,
+			Message: "chat message too long or not valid UTF-8",
+		})
+		return
+	}
+
+	h.publishEvent(types.ChatMessage{
+		Type:      string(types.ChatMsg),
+		PlayerID:  client.Player.ID,
+		NetID:     client.Player.NetID,
+		Message:   text,
+		Timestamp: types.ServerTime(time.Now()),
+	}, nil)
+}
+
+// AckEvents records that a client received every event up to and including seq
+func (h *GameHub) AckEvents(c *types.Client, seq uint64) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "eventAck", Client: c, Data: seq}:
+	default:
+		log.Printf("Client action channel full, dropping event ack from %s", c.UUID)
+	}
+}
+
+// Chat queues a chat line from the client's player
+func (h *GameHub) Chat(c *types.Client, text string) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "chat", Client: c, Data: text}:
+	default:
+		log.Printf("Client action channel full, dropping chat from %s", c.UUID)
+	}
+}
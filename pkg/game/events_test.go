@@ -0,0 +1,144 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+// publishChat publishes n chat events from a player
+func publishChat(h *GameHub, playerID string, n int) {
+	for i := 0; i < n; i++ {
+		h.publishEvent(types.ChatMessage{Type: string(types.ChatMsg), PlayerID: playerID, Message: "hi"}, nil)
+	}
+}
+
+// eventSeqs returns the sequence numbers of the events a client was sent, in order
+func eventSeqs(t *testing.T, client *types.Client) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	for _, data := range sentMessages(t, client)[string(types.EventMsg)] {
+		var event types.EventMessage
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs
+}
+
+// pendingSeqs lists the sequence numbers a player has not acknowledged
+func pendingSeqs(h *GameHub, playerID string) []uint64 {
+	var seqs []uint64
+	for _, event := range h.events[playerID].pending {
+		seqs = append(seqs, event.seq)
+	}
+	return seqs
+}
+
+func TestEventsAreSequencedAndTrimmedByAcks(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureReliableEvents)
+	first := h.events["p1"].lastSeq + 1
+	h.acknowledgeEvents(client, first-1)
+	sentMessages(t, client)
+
+	publishChat(h, "p1", 3)
+	want := []uint64{first, first + 1, first + 2}
+	if got := eventSeqs(t, client); !slices.Equal(got, want) {
+		t.Fatalf("sent seqs %v, want %v", got, want)
+	}
+
+	h.acknowledgeEvents(client, first+1)
+	if got := pendingSeqs(h, "p1"); !slices.Equal(got, want[2:]) {
+		t.Errorf("pending after ack %v, want %v", got, want[2:])
+	}
+
+	// An ack from a replaced connection is ignored
+	stale := &types.Client{UUID: "p1"}
+	h.acknowledgeEvents(stale, first+2)
+	if got := pendingSeqs(h, "p1"); len(got) != 1 {
+		t.Errorf("a stale connection's ack trimmed the log to %v", got)
+	}
+}
+
+func TestUnacknowledgedEventsAreResent(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureReliableEvents)
+	h.acknowledgeEvents(client, h.events["p1"].lastSeq)
+	sentMessages(t, client)
+
+	publishChat(h, "p1", 2)
+	seqs := eventSeqs(t, client)
+	h.acknowledgeEvents(client, seqs[0])
+
+	now := time.Now()
+	h.resendEvents(now.Add(h.config.EventResendInterval / 2))
+	if got := eventSeqs(t, client); len(got) != 0 {
+		t.Errorf("resent %v before the resend interval", got)
+	}
+
+	h.resendEvents(now.Add(h.config.EventResendInterval))
+	if got := eventSeqs(t, client); !slices.Equal(got, seqs[1:]) {
+		t.Errorf("resent %v, want only the unacknowledged %v", got, seqs[1:])
+	}
+}
+
+func TestEventsReplayOnResume(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureReliableEvents)
+	h.acknowledgeEvents(client, h.events["p1"].lastSeq)
+	sentMessages(t, client)
+
+	publishChat(h, "p1", 1)
+	h.detachEventLog("p1")
+	publishChat(h, "p1", 2) // missed while disconnected
+	want := pendingSeqs(h, "p1")
+
+	resumed := &types.Client{
+		UUID:     "p1",
+		Send:     make(chan []byte, 16),
+		Features: map[string]bool{types.FeatureReliableEvents: true},
+	}
+	h.resumeEventLog(resumed)
+	if got := eventSeqs(t, resumed); len(want) != 3 || !slices.Equal(got, want) {
+		t.Errorf("replayed %v, want every unacknowledged event %v", got, want)
+	}
+
+	// The old connection no longer acknowledges for the player
+	h.acknowledgeEvents(client, want[2])
+	if got := pendingSeqs(h, "p1"); !slices.Equal(got, want) {
+		t.Errorf("old connection's ack trimmed the log to %v", got)
+	}
+}
+
+func TestEventLogOverflowDisconnects(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.MaxPendingEvents = 3
+	h := newTestHub(t, config)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureReliableEvents)
+	h.acknowledgeEvents(client, h.events["p1"].lastSeq)
+
+	publishChat(h, "p1", 3)
+	if client.CloseCode != 0 {
+		t.Fatal("disconnected at the pending limit")
+	}
+
+	publishChat(h, "p1", 1)
+	if got := len(h.events["p1"].pending); got != 3 {
+		t.Errorf("%d events pending, want the oldest dropped down to 3", got)
+	}
+	if client.CloseCode != types.ClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", client.CloseCode, types.ClosePolicyViolation)
+	}
+	select {
+	case c := <-h.unregister:
+		if c != client {
+			t.Errorf("unregistered %s, want the lagging client", c.UUID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lagging client was not unregistered")
+	}
+}
@@ -0,0 +1,460 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/protocol"
+	"game-server-v1/pkg/types"
+	"slices"
+	"time"
+)
+
+// playerChange is a player as a client last acknowledged it and as it is now;
+// known is false for players new to the client
+type playerChange struct {
+	old, cur types.PlayerSnapshot
+	known    bool
+}
+
+// projectileChange is a projectile as a client last acknowledged it and as it is now;
+// known is false for projectiles new to the client
+type projectileChange struct {
+	old, cur types.ProjectileSnapshot
+	known    bool
+}
+
+// fragment is one cached entity encoding and the state or change it encodes
+type fragment[K comparable] struct {
+	key  K
+	data []byte
+}
+
+// fragments holds the encodings of one kind of entity by id. Clients usually see an
+// entity in the same state, so an id rarely has more than a few.
+type fragments[K comparable] map[string][]fragment[K]
+
+func (f fragments[K]) get(id string, key K) ([]byte, bool) {
+	for _, frag := range f[id] {
+		if frag.key == key {
+			return frag.data, true
+		}
+	}
+	return nil, false
+}
+
+func (f fragments[K]) put(id string, key K, data []byte) {
+	f[id] = append(f[id], fragment[K]{key: key, data: data})
+}
+
+// fragmentCache encodes each entity once per tick in one wire format. Clients that see
+// an entity in the same state, or the same change of it since their baseline, share
+// its encoding, so a per-client snapshot is only assembled from fragments.
+// A nil delta fragment means the entity did not change.
+type fragmentCache struct {
+	hub              *GameHub
+	encoding         string
+	players          fragments[types.PlayerSnapshot]
+	projectiles      fragments[types.ProjectileSnapshot]
+	playerDeltas     fragments[playerChange]
+	projectileDeltas fragments[projectileChange]
+	changed          [][]byte // scratch for the fragments of one snapshot
+}
+
+func (h *GameHub) newFragmentCache(encoding string) *fragmentCache {
+	return &fragmentCache{
+		hub:              h,
+		encoding:         encoding,
+		players:          make(fragments[types.PlayerSnapshot]),
+		projectiles:      make(fragments[types.ProjectileSnapshot]),
+		playerDeltas:     make(fragments[playerChange]),
+		projectileDeltas: make(fragments[projectileChange]),
+	}
+}
+
+// player returns the full encoding of a player
+func (c *fragmentCache) player(p types.PlayerSnapshot) ([]byte, error) {
+	if frag, ok := c.players.get(p.ID, p); ok {
+		return frag, nil
+	}
+
+	var frag []byte
+	if c.encoding == types.EncodingBinary {
+		var w protocol.Writer
+		writePlayerBinary(&w, &p)
+		frag = w.Bytes()
+	} else {
+		var err error
+		if frag, err = jsonMember(p.ID, &p); err != nil {
+			return nil, err
+		}
+	}
+	c.players.put(p.ID, p, frag)
+	return frag, nil
+}
+
+// projectile returns the full encoding of a projectile
+func (c *fragmentCache) projectile(proj types.ProjectileSnapshot) ([]byte, error) {
+	if frag, ok := c.projectiles.get(proj.ID, proj); ok {
+		return frag, nil
+	}
+
+	var frag []byte
+	if c.encoding == types.EncodingBinary {
+		var w protocol.Writer
+		writeProjectileBinary(&w, &proj)
+		frag = w.Bytes()
+	} else {
+		var err error
+		if frag, err = jsonMember(proj.ID, &proj); err != nil {
+			return nil, err
+		}
+	}
+	c.projectiles.put(proj.ID, proj, frag)
+	return frag, nil
+}
+
+// playerDelta returns the encoded change of a player, or nil if nothing changed
+func (c *fragmentCache) playerDelta(change playerChange) ([]byte, error) {
+	if change.known && change.old == change.cur {
+		return nil, nil
+	}
+	if frag, ok := c.playerDeltas.get(change.cur.ID, change); ok {
+		return frag, nil
+	}
+
+	var old *types.PlayerSnapshot
+	if change.known {
+		old = &change.old
+	}
+
+	var frag []byte
+	if c.encoding == types.EncodingBinary {
+		if mask := playerMask(old, &change.cur); mask != 0 {
+			var w protocol.Writer
+			writePlayerDeltaBinary(&w, &change.cur, mask)
+			frag = w.Bytes()
+		}
+	} else if delta := diffPlayer(old, &change.cur); delta != nil {
+		var err error
+		if frag, err = jsonMember(change.cur.ID, delta); err != nil {
+			return nil, err
+		}
+	}
+	c.playerDeltas.put(change.cur.ID, change, frag)
+	return frag, nil
+}
+
+// projectileDelta returns the encoded change of a projectile, or nil if nothing changed
+func (c *fragmentCache) projectileDelta(change projectileChange) ([]byte, error) {
+	if change.known && change.old == change.cur {
+		return nil, nil
+	}
+	if frag, ok := c.projectileDeltas.get(change.cur.ID, change); ok {
+		return frag, nil
+	}
+
+	var old *types.ProjectileSnapshot
+	if change.known {
+		old = &change.old
+	}
+
+	var frag []byte
+	if c.encoding == types.EncodingBinary {
+		if mask := projectileMask(old, &change.cur); mask != 0 {
+			var w protocol.Writer
+			writeProjectileDeltaBinary(&w, &change.cur, mask)
+			frag = w.Bytes()
+		}
+	} else if delta := diffProjectile(old, &change.cur); delta != nil {
+		var err error
+		if frag, err = jsonMember(change.cur.ID, delta); err != nil {
+			return nil, err
+		}
+	}
+	c.projectileDeltas.put(change.cur.ID, change, frag)
+	return frag, nil
+}
+
+// assemble encodes snap in full when base is nil, otherwise as a delta against base
+func (c *fragmentCache) assemble(base, snap *snapshot) ([]byte, error) {
+	if c.encoding == types.EncodingBinary {
+		if base == nil {
+			return c.assembleBinary(snap)
+		}
+		return c.assembleDeltaBinary(base, snap)
+	}
+
+	if base == nil {
+		return c.assembleJSON(snap)
+	}
+	return c.assembleDeltaJSON(base, snap)
+}
+
+// assembleBinary writes a full snapshot:
+// header | player count | players | projectile count | projectiles
+func (c *fragmentCache) assembleBinary(snap *snapshot) ([]byte, error) {
+	w := protocol.NewWriter(protocol.OpGameState)
+	c.hub.writeSnapshotHeader(w, snap.tick)
+
+	w.Uvarint(uint64(len(snap.players)))
+	for _, p := range snap.players {
+		frag, err := c.player(p)
+		if err != nil {
+			return nil, err
+		}
+		w.Raw(frag)
+	}
+
+	w.Uvarint(uint64(len(snap.projectiles)))
+	for _, proj := range snap.projectiles {
+		frag, err := c.projectile(proj)
+		if err != nil {
+			return nil, err
+		}
+		w.Raw(frag)
+	}
+
+	return w.Bytes(), nil
+}
+
+// assembleDeltaBinary writes a delta snapshot:
+// header | baseTick | changed player count | players | removed player count | netIds
+// | same for projectiles
+func (c *fragmentCache) assembleDeltaBinary(base, snap *snapshot) ([]byte, error) {
+	w := protocol.NewWriter(protocol.OpGameStateDelta)
+	c.hub.writeSnapshotHeader(w, snap.tick)
+	w.Uvarint(base.tick)
+
+	// Players
+	c.changed = c.changed[:0]
+	for id, cur := range snap.players {
+		old, known := base.players[id]
+		frag, err := c.playerDelta(playerChange{old: old, cur: cur, known: known})
+		if err != nil {
+			return nil, err
+		}
+		if frag != nil {
+			c.changed = append(c.changed, frag)
+		}
+	}
+	writeFragments(w, c.changed)
+
+	var removed []uint32
+	for id, old := range base.players {
+		if _, ok := snap.players[id]; !ok {
+			removed = append(removed, old.NetID)
+		}
+	}
+	writeNetIDs(w, removed)
+
+	// Projectiles
+	c.changed = c.changed[:0]
+	for id, cur := range snap.projectiles {
+		old, known := base.projectiles[id]
+		frag, err := c.projectileDelta(projectileChange{old: old, cur: cur, known: known})
+		if err != nil {
+			return nil, err
+		}
+		if frag != nil {
+			c.changed = append(c.changed, frag)
+		}
+	}
+	writeFragments(w, c.changed)
+
+	removed = removed[:0]
+	for id, old := range base.projectiles {
+		if _, ok := snap.projectiles[id]; !ok {
+			removed = append(removed, old.NetID)
+		}
+	}
+	writeNetIDs(w, removed)
+
+	return w.Bytes(), nil
+}
+
+// writeFragments writes a count followed by the fragments
+func writeFragments(w *protocol.Writer, frags [][]byte) {
+	w.Uvarint(uint64(len(frags)))
+	for _, frag := range frags {
+		w.Raw(frag)
+	}
+}
+
+// writeNetIDs writes a count followed by the netIds
+func writeNetIDs(w *protocol.Writer, netIDs []uint32) {
+	w.Uvarint(uint64(len(netIDs)))
+	for _, netID := range netIDs {
+		w.Uvarint(uint64(netID))
+	}
+}
+
+// snapshotHeader is the metadata a JSON snapshot starts with. The entity fields of
+// types.GameStateMessage and types.GameStateDeltaMessage follow it, assembled from fragments.
+type snapshotHeader struct {
+	Type         string  `json:"type"`
+	Tick         uint64  `json:"tick"`
+	BaseTick     *uint64 `json:"baseTick,omitempty"`
+	TickInterval float64 `json:"tickInterval"`
+	ServerTime   float64 `json:"serverTime"`
+	Timestamp    float64 `json:"timestamp"`
+}
+
+// openJSON starts a JSON snapshot with its header, leaving the object open for entity fields
+func (c *fragmentCache) openJSON(msgType types.MessageType, base, snap *snapshot) ([]byte, error) {
+	header := snapshotHeader{
+		Type:         string(msgType),
+		Tick:         snap.tick,
+		TickInterval: c.hub.config.TickInterval.Seconds(),
+		ServerTime:   types.ServerTime(time.Now()),
+		Timestamp:    types.ServerTime(snap.time),
+	}
+	if base != nil {
+		header.BaseTick = &base.tick
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	return data[:len(data)-1], nil
+}
+
+// assembleJSON encodes a full snapshot as a types.GameStateMessage
+func (c *fragmentCache) assembleJSON(snap *snapshot) ([]byte, error) {
+	buf, err := c.openJSON(types.GameStateMsg, nil, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	c.changed = c.changed[:0]
+	for _, p := range snap.players {
+		frag, err := c.player(p)
+		if err != nil {
+			return nil, err
+		}
+		c.changed = append(c.changed, frag)
+	}
+	players := c.changed[:len(c.changed):len(c.changed)]
+	for _, proj := range snap.projectiles {
+		frag, err := c.projectile(proj)
+		if err != nil {
+			return nil, err
+		}
+		c.changed = append(c.changed, frag)
+	}
+	projectiles := c.changed[len(players):]
+
+	buf = slices.Grow(buf, membersSize(c.changed))
+	buf = appendJSONObject(buf, "players", players)
+	buf = appendJSONObject(buf, "projectiles", projectiles)
+	return append(buf, '}'), nil
+}
+
+// assembleDeltaJSON encodes a delta snapshot as a types.GameStateDeltaMessage
+func (c *fragmentCache) assembleDeltaJSON(base, snap *snapshot) ([]byte, error) {
+	buf, err := c.openJSON(types.GameStateDeltaMsg, base, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	c.changed = c.changed[:0]
+	for id, cur := range snap.players {
+		old, known := base.players[id]
+		frag, err := c.playerDelta(playerChange{old: old, cur: cur, known: known})
+		if err != nil {
+			return nil, err
+		}
+		if frag != nil {
+			c.changed = append(c.changed, frag)
+		}
+	}
+	players := c.changed[:len(c.changed):len(c.changed)]
+	for id, cur := range snap.projectiles {
+		old, known := base.projectiles[id]
+		frag, err := c.projectileDelta(projectileChange{old: old, cur: cur, known: known})
+		if err != nil {
+			return nil, err
+		}
+		if frag != nil {
+			c.changed = append(c.changed, frag)
+		}
+	}
+	projectiles := c.changed[len(players):]
+
+	var removedPlayers, removedProjectiles []string
+	for id := range base.players {
+		if _, ok := snap.players[id]; !ok {
+			removedPlayers = append(removedPlayers, id)
+		}
+	}
+	for id := range base.projectiles {
+		if _, ok := snap.projectiles[id]; !ok {
+			removedProjectiles = append(removedProjectiles, id)
+		}
+	}
+
+	buf = slices.Grow(buf, membersSize(c.changed))
+	if len(players) > 0 {
+		buf = appendJSONObject(buf, "players", players)
+	}
+	if buf, err = appendJSONList(buf, "removedPlayers", removedPlayers); err != nil {
+		return nil, err
+	}
+	if len(projectiles) > 0 {
+		buf = appendJSONObject(buf, "projectiles", projectiles)
+	}
+	if buf, err = appendJSONList(buf, "removedProjectiles", removedProjectiles); err != nil {
+		return nil, err
+	}
+	return append(buf, '}'), nil
+}
+
+// membersSize is roughly how many bytes the JSON objects holding members take
+func membersSize(members [][]byte) int {
+	size := 64 // field names and braces
+	for _, member := range members {
+		size += len(member) + 1
+	}
+	return size
+}
+
+// jsonMember encodes one `"key":value` member of a JSON object
+func jsonMember(key string, value any) ([]byte, error) {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(k, ':'), v...), nil
+}
+
+// appendJSONObject appends `,"name":{members}` to an open JSON object
+func appendJSONObject(buf []byte, name string, members [][]byte) []byte {
+	buf = append(buf, `,"`...)
+	buf = append(buf, name...)
+	buf = append(buf, `":{`...)
+	for i, member := range members {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, member...)
+	}
+	return append(buf, '}')
+}
+
+// appendJSONList appends `,"name":[ids]` to an open JSON object, nothing when ids is empty
+func appendJSONList(buf []byte, name string, ids []string) ([]byte, error) {
+	if len(ids) == 0 {
+		return buf, nil
+	}
+	list, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, `,"`...)
+	buf = append(buf, name...)
+	buf = append(buf, `":`...)
+	return append(buf, list...), nil
+}
@@ -1,21 +1,16 @@
 package game
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"game-server-v1/pkg/types"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// GameState holds the authoritative state of the game world
-type GameState struct {
-	Players     map[string]*types.Player     // PlayerID → Player state
-	Projectiles map[string]*types.Projectile // ProjectileID → Projectile state
-	LastUpdate  time.Time
-	mu          sync.RWMutex
-}
-
 // GameHub is the central coordinator for all game operations
 type GameHub struct {
 	// Client management
@@ -26,9 +21,6 @@ type GameHub struct {
 	players    map[string]*types.Player
 	playersMux sync.RWMutex
 
-	//projectiles
-	Projectiles map[string]*Projectile // add projectile state
-
 	// Channels for client lifecycle
 	register   chan *types.Client
 	unregister chan *types.Client
@@ -41,11 +33,31 @@ type GameHub struct {
 	// Channel for GameState updates
 	gameStateUpdate chan *GameState
 
+	// Buffered inputs per player, only touched by the run loop
+	inputs map[string]*inputBuffer
+
+	// Per-client replication state, only touched by the run loop
+	views map[*types.Client]*clientView
+
+	// Reliable event streams by player ID, only touched by the run loop
+	events map[string]*eventLog
+
+	// nextNetID is the last numeric entity ID handed out, only touched by the run loop
+	nextNetID uint32
+
+	// Resume tokens and players awaiting reconnection
+	sessions *sessionStore
+
 	// Game configuration
 	config *types.GameConfig
 
-	// Game state
-	isRunning bool
+	// Static map geometry
+	world *World
+
+	// Lifecycle; done is closed once the run loop has exited and closed every client
+	running   atomic.Bool
+	cancel    context.CancelCauseFunc
+	done      chan struct{}
 	startTime time.Time
 	state     *GameState
 
@@ -60,7 +72,24 @@ type GameStats struct {
 	MessagesPerSec   float64       `json:"messagesPerSecond"`
 	Uptime           time.Duration `json:"uptime"`
 	LastUpdate       time.Time     `json:"lastUpdate"`
-	mu               sync.RWMutex
+
+	// Tick timings; averages are exponential moving averages
+	AvgSimTime  time.Duration `json:"avgSimTime"`  // simulation step only
+	AvgTickTime time.Duration `json:"avgTickTime"` // simulation plus broadcast
+	MaxTickTime time.Duration `json:"maxTickTime"`
+
+	// Outbound backlog: snapshots replaced before being sent, clients currently
+	// falling behind, and the longest queue of other messages
+	ConflatedSnapshots int64 `json:"conflatedSnapshots"`
+	LaggingClients     int   `json:"laggingClients"`
+	MaxSendBacklog     int   `json:"maxSendBacklog"`
+
+	// Round-trip times from time-sync pings, overall and by player ID
+	AvgRTT    time.Duration            `json:"avgRtt"`
+	MaxRTT    time.Duration            `json:"maxRtt"`
+	PlayerRTT map[string]time.Duration `json:"playerRtt"`
+
+	mu sync.RWMutex
 }
 
 // NewGameHub creates and initializes a new GameHub
@@ -69,6 +98,17 @@ func NewGameHub(config *types.GameConfig) *GameHub {
 		config = types.GetDefaultConfig()
 	}
 
+	world := NewWorld(config)
+	if config.MapFile != "" {
+		loaded, err := LoadWorld(config.MapFile, config)
+		if err != nil {
+			log.Printf("Error loading map, using empty world: %v", err)
+		} else {
+			world = loaded
+		}
+	}
+	world.BuildIndex(config.SpatialCellSize)
+
 	return &GameHub{
 		clients:         make(map[*types.Client]bool),
 		players:         make(map[string]*types.Player),
@@ -78,54 +118,83 @@ func NewGameHub(config *types.GameConfig) *GameHub {
 		playerInput:     make(chan *types.PlayerInputMessage, 1000),
 		clientAction:    make(chan *types.ClientAction, 500),
 		gameStateUpdate: make(chan *GameState, 100), // New channel for GameState updates
+		inputs:          make(map[string]*inputBuffer),
+		views:           make(map[*types.Client]*clientView),
+		events:          make(map[string]*eventLog),
+		sessions:        newSessionStore(),
 		config:          config,
-		isRunning:       false,
-		stats:           &GameStats{LastUpdate: time.Now()},
+		world:           world,
+		done:            make(chan struct{}),
+		stats:           &GameStats{LastUpdate: time.Now(), PlayerRTT: make(map[string]time.Duration)},
 		state: &GameState{
 			Players:     make(map[string]*types.Player),
 			Projectiles: make(map[string]*types.Projectile),
 			LastUpdate:  time.Now(),
+			history:     newPositionHistory(config.MaxRewind, config.TickInterval),
+			grid:        NewSpatialGrid(config.SpatialCellSize),
+
+			alwaysRelevant: make(map[string]bool),
 		},
 	}
 }
 
-// Start begins the GameHub operation
-func (h *GameHub) Start() {
-	h.isRunning = true
+// Start begins the GameHub operation. The hub runs until ctx is cancelled or Stop is called.
+func (h *GameHub) Start(ctx context.Context) {
+	ctx, h.cancel = context.WithCancelCause(ctx)
+	h.running.Store(true)
 	h.startTime = time.Now()
 
 	log.Println("GameHub starting...")
 
 	// Start the main game loop
-	go h.run()
+	go h.run(ctx)
 
 	// Start statistics updater
-	go h.updateStats()
+	go h.updateStats(ctx)
 
 	log.Println("GameHub started successfully")
 }
 
-// Stop gracefully shuts down the GameHub
+// Stop shuts down the GameHub and waits until every client has been told to close
 func (h *GameHub) Stop() {
-	h.isRunning = false
+	h.StopWithReason("server shutting down")
+}
 
-	// Close all client connections
-	h.clientsMux.Lock()
-	for client := range h.clients {
-		close(client.Send)
+// StopWithReason shuts down the GameHub like Stop, giving clients reason in their close frame
+func (h *GameHub) StopWithReason(reason string) {
+	if !h.running.Load() {
+		return
 	}
-	h.clientsMux.Unlock()
+	h.cancel(errors.New(reason))
+	<-h.done
 
 	log.Println("GameHub stopped")
 }
 
+// Done is closed once the hub has stopped
+func (h *GameHub) Done() <-chan struct{} {
+	return h.done
+}
+
 // run is the main game loop
-func (h *GameHub) run() {
+func (h *GameHub) run(ctx context.Context) {
 	ticker := time.NewTicker(h.config.TickInterval)
 	defer ticker.Stop()
+	defer close(h.done)
+	defer func() {
+		reason := "server shutting down"
+		if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+			reason = cause.Error()
+		}
+		h.closeAllClients(types.CloseGoingAway, reason)
+	}()
 
-	for h.isRunning {
+	for {
 		select {
+		case <-ctx.Done():
+			h.running.Store(false)
+			return
+
 		case <-ticker.C:
 			h.gameTick()
 
@@ -133,15 +202,18 @@ func (h *GameHub) run() {
 			h.handleClientRegister(client)
 
 		case client := <-h.unregister:
+			h.flushRegistrations()
 			h.handleClientUnregister(client)
 
 		case input := <-h.playerInput:
+			h.flushRegistrations()
 			h.handlePlayerInput(input)
 
 		case message := <-h.broadcast:
 			h.handleBroadcast(message)
 
 		case action := <-h.clientAction:
+			h.flushRegistrations()
 			h.handleClientAction(action)
 
 		case gameState := <-h.gameStateUpdate:
@@ -150,44 +222,104 @@ func (h *GameHub) run() {
 	}
 }
 
-// gameTick performs regular game updates - only broadcasts current state
+// flushRegistrations registers every queued client. Select picks among ready
+// channels at random, so without this a connection's first messages, or its
+// unregistration, could be handled before its registration.
+func (h *GameHub) flushRegistrations() {
+	for {
+		select {
+		case client := <-h.register:
+			h.handleClientRegister(client)
+		default:
+			return
+		}
+	}
+}
+
+// gameTick advances the simulation by one step and broadcasts the result
 func (h *GameHub) gameTick() {
+	start := time.Now()
+
+	h.expireSessions(start)
+	h.sendPings(start)
+	h.resendEvents(start)
+	h.updateGameState()
+	simTime := time.Since(start)
+
 	// Create a snapshot of the current state and broadcast to all clients
 	stateCopy := h.snapshotState()
 	h.broadcastGameState(stateCopy)
+
+	h.recordTickTime(simTime, time.Since(start))
 }
 
-// broadcastGameState sends the GameState to all connected clients via their WritePump
-func (h *GameHub) broadcastGameState(gameState *GameState) {
-	// Marshal the GameState to JSON
-	gameStateMsg := types.GameStateMessage{
-		Type:      string(types.GameStateMsg),
-		Players:   gameState.Players,
-		Timestamp: float64(gameState.LastUpdate.UnixNano()) / 1e9,
-	}
+// recordTickTime folds one tick's timings into the statistics
+func (h *GameHub) recordTickTime(simTime, tickTime time.Duration) {
+	const weight = 0.1
 
-	data, err := json.Marshal(gameStateMsg)
-	if err != nil {
-		log.Printf("Error marshaling game state: %v", err)
-		return
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+
+	if h.stats.AvgTickTime == 0 {
+		h.stats.AvgSimTime = simTime
+		h.stats.AvgTickTime = tickTime
+	} else {
+		h.stats.AvgSimTime += time.Duration(weight * float64(simTime-h.stats.AvgSimTime))
+		h.stats.AvgTickTime += time.Duration(weight * float64(tickTime-h.stats.AvgTickTime))
 	}
+	if tickTime > h.stats.MaxTickTime {
+		h.stats.MaxTickTime = tickTime
+	}
+}
+
+// broadcastGameState sends the GameState to all connected clients via their WritePump,
+// as a delta against each client's acknowledged baseline where possible
+func (h *GameHub) broadcastGameState(gameState *GameState) {
+	snap := newSnapshot(gameState)
+	encoder := h.newSnapshotEncoder(snap)
+	now := time.Now()
 
 	// Send to each client individually through their WritePump
 	h.clientsMux.RLock()
 	defer h.clientsMux.RUnlock()
 
+	// Interest filtering queries the spatial grid, which the state lock protects
+	h.state.mu.RLock()
+	defer h.state.mu.RUnlock()
+
 	for client := range h.clients {
-		select {
-		case client.Send <- data:
-			// Successfully queued for client's WritePump
-		default:
-			// Client's send channel is full, disconnect them
-			log.Printf("Client %s send buffer full, disconnecting", client.UUID)
-			go func(c *types.Client) {
-				h.unregister <- c
-			}(client)
+		view, ok := h.views[client]
+		if !ok {
+			continue
+		}
+
+		h.refillBudget(client, view)
+		if !h.snapshotDue(client, view) {
+			continue
+		}
+
+		visible, entities, send := h.packSnapshot(client, view, h.filterSnapshot(snap, client.UUID))
+		if !send {
+			continue
 		}
+
+		change := h.updateInterest(view, visible, snap)
+		if change != nil && client.HasFeature(types.FeatureInterestEvents) {
+			h.sendToClient(client, change)
+		}
+
+		data, err := encoder.encode(view, visible, client.Encoding)
+		if err != nil {
+			log.Printf("Error marshaling game state for client %s: %v", client.UUID, err)
+			continue
+		}
+
+		view.remember(visible, h.config.SnapshotHistory)
+		h.spendBudget(client, view, len(data), entities)
+		h.queueSnapshot(client, view, data, now)
 	}
+
+	h.recordBacklog()
 }
 
 // handleGameStateUpdate processes external GameState updates
@@ -197,6 +329,7 @@ func (h *GameHub) handleGameStateUpdate(newState *GameState) {
 	h.state.Players = newState.Players
 	h.state.Projectiles = newState.Projectiles
 	h.state.LastUpdate = newState.LastUpdate
+	h.rebuildSpatialGrid()
 	h.state.mu.Unlock()
 
 	// Broadcast the updated state to all clients
@@ -223,6 +356,7 @@ func (h *GameHub) snapshotState() *GameState {
 	return &GameState{
 		Players:     playersCopy,
 		Projectiles: projectilesCopy,
+		Tick:        h.state.Tick,
 		LastUpdate:  h.state.LastUpdate,
 	}
 }
@@ -239,10 +373,21 @@ func (h *GameHub) UpdateGameState(newState *GameState) {
 
 // handleClientRegister processes new client registrations
 func (h *GameHub) handleClientRegister(client *types.Client) {
+	if h.roomFull(client) {
+		// Closing Send without registering makes the WritePump close the connection
+		log.Printf("Client %s rejected, room is full", client.UUID)
+		client.CloseCode = types.CloseTryAgainLater
+		client.CloseReason = "room is full"
+		close(client.Send)
+		return
+	}
+
 	h.clientsMux.Lock()
 	h.clients[client] = true
 	h.clientsMux.Unlock()
 
+	h.views[client] = newClientView()
+
 	// Update statistics
 	h.stats.mu.Lock()
 	h.stats.TotalConnections++
@@ -251,36 +396,148 @@ func (h *GameHub) handleClientRegister(client *types.Client) {
 
 	log.Printf("Client %s registered", client.UUID)
 
+	if h.resumeSession(client) {
+		return
+	}
+
+	// Create the player controlled by this client
+	player := types.NewPlayer(client.UUID)
+	player.NetID = h.allocNetID()
+	player.MoveSpeed = h.config.MoveSpeed
+	client.Player = player
+
+	h.state.mu.Lock()
+	h.spawnPlayer(player, time.Now())
+	h.state.Players[player.ID] = player
+	h.state.mu.Unlock()
+
+	h.inputs[player.ID] = &inputBuffer{}
+
+	token := h.sessions.open(player.ID, client)
+	h.sendPlayerID(client, token, false)
+	h.openEventLog(client)
+	h.broadcastPlayerJoined(player)
+
 	// Send current game state to the new client
 	stateCopy := h.snapshotState()
 	h.sendGameStateToClient(client, stateCopy)
 }
 
-// sendGameStateToClient sends the current GameState to a specific client
-func (h *GameHub) sendGameStateToClient(client *types.Client, gameState *GameState) {
-	gameStateMsg := types.GameStateMessage{
-		Type:      string(types.GameStateMsg),
-		Players:   gameState.Players,
-		Timestamp: float64(gameState.LastUpdate.UnixNano()) / 1e9,
+// roomFull reports whether the client needs a new player but MaxPlayers are already
+// in the world. Players held for resume keep their slot.
+func (h *GameHub) roomFull(client *types.Client) bool {
+	h.state.mu.RLock()
+	defer h.state.mu.RUnlock()
+
+	if _, ok := h.state.Players[client.UUID]; ok {
+		return false
+	}
+	return len(h.state.Players) >= h.config.MaxPlayers
+}
+
+// sendPlayerID tells a client which player it controls
+func (h *GameHub) sendPlayerID(client *types.Client, token string, resumed bool) {
+	idMsg := types.PlayerIDMessage{
+		Type:        string(types.PlayerIDMsg),
+		PlayerID:    client.Player.ID,
+		NetID:       client.Player.NetID,
+		ResumeToken: token,
+		Resumed:     resumed,
 	}
 
-	data, err := json.Marshal(gameStateMsg)
+	data, err := json.Marshal(idMsg)
 	if err != nil {
-		log.Printf("Error marshaling game state for client %s: %v", client.UUID, err)
+		log.Printf("Error marshaling player id for client %s: %v", client.UUID, err)
 		return
 	}
 
 	select {
 	case client.Send <- data:
-		// Successfully sent
 	default:
-		// Client buffer full
-		log.Printf("Client %s buffer full when sending initial state", client.UUID)
+		log.Printf("Client %s buffer full when sending player id", client.UUID)
+	}
+}
+
+// sendGameStateToClient sends a full snapshot of the GameState to a specific client
+// and resets its delta baselines
+func (h *GameHub) sendGameStateToClient(client *types.Client, gameState *GameState) {
+	view, ok := h.views[client]
+	if !ok {
+		return
+	}
+	view.reset()
+
+	h.state.mu.RLock()
+	snap := h.filterSnapshot(newSnapshot(gameState), client.UUID)
+	h.state.mu.RUnlock()
+
+	// The full snapshot already tells the client what it can see
+	view.interest = newInterestSet()
+	for id := range snap.players {
+		view.interest.players[id] = true
+	}
+	for id := range snap.projectiles {
+		view.interest.projectiles[id] = true
+	}
+
+	data, err := h.encodeSnapshot(nil, snap, client.Encoding)
+	if err != nil {
+		log.Printf("Error marshaling game state for client %s: %v", client.UUID, err)
+		return
+	}
+
+	view.remember(snap, h.config.SnapshotHistory)
+	h.spendBudget(client, view, len(data), len(snap.players)+len(snap.projectiles))
+	h.queueSnapshot(client, view, data, time.Now())
+}
+
+// kick is a request to disconnect a client with a close code and reason
+type kick struct {
+	code   int
+	reason string
+}
+
+// kickClient sets the close frame a client gets and unregisters it
+func (h *GameHub) kickClient(client *types.Client, k kick) {
+	if _, ok := h.views[client]; !ok {
+		return
 	}
+	client.CloseCode = k.code
+	client.CloseReason = k.reason
+	h.handleClientUnregister(client)
 }
 
-// handleClientUnregister processes client disconnections
+// handleClientUnregister processes client disconnections. A connection can be
+// unregistered by both the hub and its ReadPump; only the first one counts.
 func (h *GameHub) handleClientUnregister(client *types.Client) {
+	if _, ok := h.views[client]; !ok {
+		return
+	}
+	h.dropClient(client)
+
+	// Hold the player for the reconnect grace period, or remove it right away
+	if client.Player != nil {
+		id := client.Player.ID
+		if h.sessions.detach(client, time.Now()) && h.config.ReconnectGrace > 0 {
+			if buf, ok := h.inputs[id]; ok {
+				buf.reset()
+			}
+			h.detachEventLog(id)
+			log.Printf("Player %s disconnected, holding for %v", id, h.config.ReconnectGrace)
+		} else {
+			h.removePlayer(id)
+			log.Printf("Player %s disconnected", id)
+		}
+	}
+
+	// Update statistics
+	h.stats.mu.Lock()
+	h.stats.ActivePlayers = len(h.clients)
+	h.stats.mu.Unlock()
+}
+
+// dropClient closes a connection's outbound queue and forgets its replication state
+func (h *GameHub) dropClient(client *types.Client) {
 	h.clientsMux.Lock()
 	if _, ok := h.clients[client]; ok {
 		delete(h.clients, client)
@@ -288,29 +545,44 @@ func (h *GameHub) handleClientUnregister(client *types.Client) {
 	}
 	h.clientsMux.Unlock()
 
-	// Remove player if exists
-	if client.Player != nil {
-		h.state.mu.Lock()
-		delete(h.state.Players, client.Player.ID)
-		h.state.mu.Unlock()
-
-		// Broadcast player left
-		h.broadcastPlayerLeft(client.Player.ID)
+	delete(h.views, client)
+}
 
-		log.Printf("Player %s disconnected", client.Player.ID)
+// removePlayer deletes a player from the world and tells everyone it left
+func (h *GameHub) removePlayer(playerID string) {
+	h.state.mu.Lock()
+	var netID uint32
+	if player, ok := h.state.Players[playerID]; ok {
+		netID = player.NetID
 	}
+	delete(h.state.Players, playerID)
+	delete(h.state.alwaysRelevant, playerID)
+	h.state.mu.Unlock()
 
-	// Update statistics
-	h.stats.mu.Lock()
-	h.stats.ActivePlayers = len(h.clients)
-	h.stats.mu.Unlock()
+	delete(h.inputs, playerID)
+	delete(h.events, playerID)
+	h.sessions.close(playerID)
+	h.forgetRTT(playerID)
+
+	// Broadcast player left
+	h.broadcastPlayerLeft(playerID, netID)
 }
 
-// handlePlayerInput processes player input messages
+// handlePlayerInput buffers player input until the next simulation tick
 func (h *GameHub) handlePlayerInput(input *types.PlayerInputMessage) {
-	// Player input is handled externally in player.go
-	// This is kept for compatibility but does nothing
-	log.Printf("Player input received for %s (handled externally)", input.PlayerID)
+	buf, ok := h.inputs[input.PlayerID]
+	if !ok {
+		log.Printf("Input for unknown player %s", input.PlayerID)
+		return
+	}
+
+	// Validate input (anti-cheat sanity check)
+	if !input.IsValidMovement() {
+		log.Printf("Invalid movement input from %s", input.PlayerID)
+		return
+	}
+
+	buf.push(input)
 }
 
 // handleBroadcast sends messages to all connected clients (legacy method)
@@ -322,8 +594,8 @@ func (h *GameHub) handleBroadcast(message []byte) {
 		select {
 		case client.Send <- message:
 		default:
-			delete(h.clients, client)
-			close(client.Send)
+			// Slow clients are disconnected by the lag check, not by a burst of messages
+			log.Printf("Client %s send buffer full, dropping broadcast", client.UUID)
 		}
 	}
 }
@@ -332,36 +604,170 @@ func (h *GameHub) handleBroadcast(message []byte) {
 func (h *GameHub) handleClientAction(action *types.ClientAction) {
 	switch action.Type {
 	case "sendToClient":
-		if data, ok := action.Data.([]byte); ok {
+		h.clientsMux.RLock()
+		_, registered := h.clients[action.Client]
+		h.clientsMux.RUnlock()
+
+		// The client may have disconnected and had its Send channel closed since queuing
+		if data, ok := action.Data.([]byte); ok && registered {
 			select {
 			case action.Client.Send <- data:
 			default:
-				// Client buffer full, disconnect
-				h.unregister <- action.Client
+				log.Printf("Client %s buffer full, dropping message", action.Client.UUID)
 			}
 		}
 	case "kickClient":
-		h.unregister <- action.Client
+		if k, ok := action.Data.(kick); ok {
+			h.kickClient(action.Client, k)
+		}
+	case "shoot":
+		if msg, ok := action.Data.(*types.ShootMessage); ok {
+			h.fireWeapon(action.Client, msg)
+		}
+	case "reload":
+		h.requestReload(action.Client)
+	case "switchWeapon":
+		if name, ok := action.Data.(string); ok {
+			h.switchWeapon(action.Client, name)
+		}
+	case "pong":
+		if p, ok := action.Data.(pong); ok {
+			h.handlePong(action.Client, p)
+		}
+	case "timeSync":
+		if msg, ok := action.Data.(*types.TimeSyncMessage); ok {
+			h.handleTimeSync(action.Client, msg)
+		}
+	case "eventAck":
+		if seq, ok := action.Data.(uint64); ok {
+			h.acknowledgeEvents(action.Client, seq)
+		}
+	case "chat":
+		if text, ok := action.Data.(string); ok {
+			h.handleChat(action.Client, text)
+		}
+	case "snapshotRate":
+		if rate, ok := action.Data.(int); ok {
+			h.setSnapshotRate(action.Client, rate)
+		}
+	case "snapshotAck":
+		// Without delta support the client keeps receiving full snapshots
+		if !action.Client.HasFeature(types.FeatureDeltaSnapshots) {
+			return
+		}
+		if tick, ok := action.Data.(uint64); ok {
+			if view, ok := h.views[action.Client]; ok {
+				view.acknowledge(tick)
+			}
+		}
 	}
 }
 
-// broadcastPlayerLeft sends player left message to all clients
-func (h *GameHub) broadcastPlayerLeft(playerID string) {
-	msg := map[string]string{
-		"type":     "playerLeft",
-		"playerId": playerID,
+// broadcastPlayerJoined publishes a player joined event to all clients
+func (h *GameHub) broadcastPlayerJoined(player *types.Player) {
+	h.publishEvent(types.PlayerJoinedMessage{
+		Type:     string(types.PlayerJoinedMsg),
+		PlayerID: player.ID,
+		NetID:    player.NetID,
+		PosX:     player.PosX,
+		PosY:     player.PosY,
+	}, nil)
+}
+
+// broadcastPlayerLeft publishes a player left event to all clients
+func (h *GameHub) broadcastPlayerLeft(playerID string, netID uint32) {
+	h.publishEvent(types.PlayerLeftMessage{
+		Type:     string(types.PlayerLeftMsg),
+		PlayerID: playerID,
+		NetID:    netID,
+	}, nil)
+}
+
+// sendToClient marshals a message and queues it for a single client from the game loop
+func (h *GameHub) sendToClient(client *types.Client, msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message for client %s: %v", client.UUID, err)
+		return
+	}
+
+	select {
+	case client.Send <- data:
+	default:
+		log.Printf("Client %s buffer full, dropping message", client.UUID)
+	}
+}
+
+// broadcastEncoded sends a message to every client in its negotiated encoding
+func (h *GameHub) broadcastEncoded(msg interface{}, binary []byte) {
+	text, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling broadcast message: %v", err)
+		return
+	}
+
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+
+	for client := range h.clients {
+		data := text
+		if client.Encoding == types.EncodingBinary {
+			data = binary
+		}
+		select {
+		case client.Send <- data:
+		default:
+			log.Printf("Client %s buffer full, dropping message", client.UUID)
+		}
+	}
+}
+
+// allocNetID returns the next numeric entity ID, skipping zero which means "none"
+func (h *GameHub) allocNetID() uint32 {
+	h.nextNetID++
+	if h.nextNetID == 0 {
+		h.nextNetID++
+	}
+	return h.nextNetID
+}
+
+// closeAllClients asks every connection to close once its queued messages are flushed.
+// Registrations still queued are closed as well, since the run loop will never see them.
+func (h *GameHub) closeAllClients(code int, reason string) {
+	h.clientsMux.Lock()
+	defer h.clientsMux.Unlock()
+
+	closeClient := func(client *types.Client) {
+		client.CloseCode = code
+		client.CloseReason = reason
+		close(client.Send)
+	}
+
+	for client := range h.clients {
+		closeClient(client)
+		delete(h.clients, client)
+	}
+	for {
+		select {
+		case client := <-h.register:
+			closeClient(client)
+		default:
+			return
+		}
 	}
-	data, _ := json.Marshal(msg)
-	h.broadcast <- data
 }
 
 // updateStats periodically updates server statistics
-func (h *GameHub) updateStats() {
+func (h *GameHub) updateStats(ctx context.Context) {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
-	for h.isRunning {
-		<-ticker.C
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
 		h.stats.mu.Lock()
 		h.stats.Uptime = time.Since(h.startTime)
@@ -386,7 +792,37 @@ func (h *GameHub) GetClients() map[*types.Client]bool {
 func (h *GameHub) GetStats() GameStats {
 	h.stats.mu.RLock()
 	defer h.stats.mu.RUnlock()
-	return *h.stats
+
+	playerRTT := make(map[string]time.Duration, len(h.stats.PlayerRTT))
+	var totalRTT, maxRTT time.Duration
+	for id, rtt := range h.stats.PlayerRTT {
+		playerRTT[id] = rtt
+		totalRTT += rtt
+		maxRTT = max(maxRTT, rtt)
+	}
+	var avgRTT time.Duration
+	if len(playerRTT) > 0 {
+		avgRTT = totalRTT / time.Duration(len(playerRTT))
+	}
+
+	return GameStats{
+		TotalConnections: h.stats.TotalConnections,
+		ActivePlayers:    h.stats.ActivePlayers,
+		MessagesPerSec:   h.stats.MessagesPerSec,
+		Uptime:           h.stats.Uptime,
+		LastUpdate:       h.stats.LastUpdate,
+		AvgSimTime:       h.stats.AvgSimTime,
+		AvgTickTime:      h.stats.AvgTickTime,
+		MaxTickTime:      h.stats.MaxTickTime,
+
+		ConflatedSnapshots: h.stats.ConflatedSnapshots,
+		LaggingClients:     h.stats.LaggingClients,
+		MaxSendBacklog:     h.stats.MaxSendBacklog,
+
+		AvgRTT:    avgRTT,
+		MaxRTT:    maxRTT,
+		PlayerRTT: playerRTT,
+	}
 }
 
 func (h *GameHub) GetRegisterChan() chan<- *types.Client   { return h.register }
@@ -396,22 +832,128 @@ func (h *GameHub) GetPlayerInputChan() chan<- *types.PlayerInputMessage {
 	return h.playerInput
 }
 func (h *GameHub) GetClientActionChan() chan<- *types.ClientAction { return h.clientAction }
-func (h *GameHub) IsRunning() bool                                 { return h.isRunning }
+func (h *GameHub) IsRunning() bool                                 { return h.running.Load() }
 func (h *GameHub) GetConfig() *types.GameConfig                    { return h.config }
+func (h *GameHub) GetWorld() *World                                { return h.world }
+
+// PlayerCount returns how many players are in the world, including ones awaiting reconnection
+func (h *GameHub) PlayerCount() int {
+	h.state.mu.RLock()
+	defer h.state.mu.RUnlock()
+	return len(h.state.Players)
+}
 
 // GetGameState returns a snapshot of the current game state
 func (h *GameHub) GetGameState() *GameState {
 	return h.snapshotState()
 }
 
+// SetAlwaysRelevant marks a player as replicated to every client regardless of interest radius
+func (h *GameHub) SetAlwaysRelevant(playerID string, relevant bool) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	if relevant {
+		h.state.alwaysRelevant[playerID] = true
+	} else {
+		delete(h.state.alwaysRelevant, playerID)
+	}
+}
+
 // GetGameStateUpdateChan returns the channel for updating game state externally
 func (h *GameHub) GetGameStateUpdateChan() chan<- *GameState {
 	return h.gameStateUpdate
 }
 
-func (h *GameHub) AddProjectileFromClient(c *types.Client, msg *types.ProjectileMessage) {
-	projectile := NewProjectile(*msg)
-	projectile.PlayerID = c.UUID // ensure it's tied to the firing client
+// Shoot queues a fire request to be validated against the player's weapon by the game loop
+func (h *GameHub) Shoot(c *types.Client, msg *types.ShootMessage) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "shoot", Client: c, Data: msg}:
+	default:
+		log.Printf("Client action channel full, dropping shot from %s", c.UUID)
+	}
+}
+
+// AckSnapshot records that a client applied the snapshot for the given tick
+func (h *GameHub) AckSnapshot(c *types.Client, tick uint64) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "snapshotAck", Client: c, Data: tick}:
+	default:
+		log.Printf("Client action channel full, dropping snapshot ack from %s", c.UUID)
+	}
+}
+
+// Register hands a new connection to the game loop. It reports false once the hub has stopped.
+func (h *GameHub) Register(c *types.Client) bool {
+	select {
+	case <-h.done:
+		return false
+	default:
+	}
+
+	select {
+	case h.register <- c:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// Unregister tells the game loop a connection has ended, without blocking once the hub has stopped
+func (h *GameHub) Unregister(c *types.Client) {
+	select {
+	case h.unregister <- c:
+	case <-h.done:
+	}
+}
+
+// Kick unregisters a client whose connection is then closed with code and reason,
+// once its queued messages are flushed. Like Unregister it only blocks while the hub runs.
+func (h *GameHub) Kick(c *types.Client, code int, reason string) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "kickClient", Client: c, Data: kick{code: code, reason: reason}}:
+	case <-h.done:
+	}
+}
+
+// QueueInput hands a player input to the game loop, dropping it if the hub is saturated
+func (h *GameHub) QueueInput(input *types.PlayerInputMessage) {
+	select {
+	case h.playerInput <- input:
+	default:
+		log.Printf("Player input channel full, dropping input from %s", input.PlayerID)
+	}
+}
+
+// SendToClient marshals a message and queues it for a single client via the game loop
+func (h *GameHub) SendToClient(c *types.Client, msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message for client %s: %v", c.UUID, err)
+		return
+	}
+
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "sendToClient", Client: c, Data: data}:
+	default:
+		log.Printf("Client action channel full, dropping message for %s", c.UUID)
+	}
+}
 
-	h.Projectiles[projectile.ID] = projectile
+// Reload queues a reload request for the client's player
+func (h *GameHub) Reload(c *types.Client) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "reload", Client: c}:
+	default:
+		log.Printf("Client action channel full, dropping reload from %s", c.UUID)
+	}
+}
+
+// SwitchWeapon queues a request to equip the named weapon for the client's player
+func (h *GameHub) SwitchWeapon(c *types.Client, weapon string) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "switchWeapon", Client: c, Data: weapon}:
+	default:
+		log.Printf("Client action channel full, dropping weapon switch from %s", c.UUID)
+	}
 }
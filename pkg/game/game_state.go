@@ -1,130 +1,109 @@
 package game
 
-// import (
-// 	"encoding/json"
-// 	"game-server-v1/pkg/types"
-// 	"log"
-// 	"time"
-// )
-
-// // const (
-// // 	tickRate     = 20 // Server updates per second
-// // 	tickInterval = time.Second / tickRate
-// // 	moveSpeed    = 5.0  // Units per second
-// // 	maxX         = 50.0 // World boundaries
-// // 	maxY         = 50.0
-// // 	minX         = -50.0
-// // 	minY         = -50.0
-// // )
-
-// // func (h *GameHub) updateGameState() {
-// //     now := time.Now()
-
-// //     // Process all player inputs
-// //     h.playersMux.Lock()
-// //     for _, player := range h.players {
-// //         h.applyPlayerInput(player, now)
-// //     }
-// //     h.playersMux.Unlock()
-
-// //     // Process all projectiles
-// //     h.projectilesMux.Lock()
-// //     for id, proj := range h.projectiles {
-// //         if proj.Expired(now) {
-// //             delete(h.projectiles, id)
-// //             continue
-// //         }
-// //         proj.Update(h.config.TickInterval)
-// //         h.checkProjectileCollision(proj)
-// //     }
-// //     h.projectilesMux.Unlock()
-
-// //     // Broadcast full snapshot (or diffs)
-// //     h.broadcastWorldState()
-// // }
-
-// func (h *GameHub) GameLoop() {
-// 	ticker := time.NewTicker(h.config.TickInterval)
-// 	defer ticker.Stop()
-
-// 	for {
-// 		select {
-// 		// Tick-based updates
-// 		case <-ticker.C:
-// 			// this case is running based on ticker , here we have to call a function that updates the game state.
-// 			now := time.Now()
-
-// 			// Clean up inactive players
-// 			h.playersMux.Lock()
-// 			for playerID, player := range h.players {
-// 				if now.Sub(player.LastUpdate) > 30*time.Second {
-// 					log.Printf("Removing inactive player: %s", playerID)
-// 					delete(h.players, playerID)
-// 				}
-// 			}
-// 			h.playersMux.Unlock()
-
-// 			// TODO: add physics, AI, projectiles, collisions here
-
-// 		// New client registers
-// 		case client := <-h.register:
-// 			h.clientsMux.Lock()
-// 			h.clients[client] = true
-// 			h.clientsMux.Unlock()
-
-// 			// Create player for this client
-// 			player := &types.Player{
-// 				ID:         client.UUID,
-// 				PosX:       0,
-// 				PosY:       0,
-// 				MoveX:      0,
-// 				MoveY:      0,
-// 				FacingLeft: false,
-// 				MoveSpeed:  h.config.MoveSpeed,
-// 				LastUpdate: time.Now(),
-// 			}
-
-// 			client.Player = player
-
-// 			h.playersMux.Lock()
-// 			h.players[player.ID] = player
-// 			h.playersMux.Unlock()
-
-// 			// Send player ID back to client
-// 			idMsg := types.PlayerIDMessage{
-// 				Type:     "playerId",
-// 				PlayerID: player.ID,
-// 			}
-
-// 			data, err := json.Marshal(idMsg)
-// 			if err == nil {
-// 				select {
-// 				case client.Send <- data:
-// 				default:
-// 					close(client.Send)
-// 					h.clientsMux.Lock()
-// 					delete(h.clients, client)
-// 					h.clientsMux.Unlock()
-// 				}
-// 			}
-
-// 			log.Printf("Player %s connected", player.ID)
-
-// 		// Client unregisters (disconnect)
-// 		case client := <-h.unregister:
-// 			h.clientsMux.Lock()
-// 			if _, ok := h.clients[client]; ok {
-// 				delete(h.clients, client)
-// 				close(client.Send)
-// 			}
-// 			h.clientsMux.Unlock()
-
-// 			if client.Player != nil {
-// 				h.playersMux.Lock()
-// 				delete(h.players, client.Player.ID)
-// 				h.playersMux.Unlock()
-// 				log.Printf("Player %s disconnected", client.Player.ID)
-// 			}
-// 		}
-// 	}
-// }
+import (
+	"game-server-v1/pkg/types"
+	"math"
+	"sync"
+	"time"
+)
+
+// GameState holds the authoritative state of the game world
+type GameState struct {
+	Players     map[string]*types.Player     // PlayerID → Player state
+	Projectiles map[string]*types.Projectile // ProjectileID → Projectile state
+	Tick        uint64                       // number of simulation steps taken
+	LastUpdate  time.Time
+	history     *positionHistory // past player positions for lag compensation
+	grid        *SpatialGrid     // players and projectiles, rebuilt every tick
+
+	// alwaysRelevant players are replicated to every client regardless of distance
+	alwaysRelevant map[string]bool
+
+	// maxMoveSpeed is the fastest MoveSpeed of any player, used to widen rewound queries
+	maxMoveSpeed float64
+	mu           sync.RWMutex
+}
+
+// updateGameState advances the simulation by exactly one fixed tick
+func (h *GameHub) updateGameState() {
+	dt := h.config.TickInterval
+	now := time.Now()
+
+	h.state.mu.Lock()
+	h.state.Tick++
+
+	// Apply every input received since the last tick, each for its share of the
+	// tick, so a player never moves more than one tick's worth
+	for id, player := range h.state.Players {
+		buf, ok := h.inputs[id]
+		if !ok {
+			continue
+		}
+		inputs := buf.take(h.config.InputRepeatTicks)
+		if len(inputs) == 0 {
+			if player.IsAlive {
+				h.applyPlayerInput(player, nil, dt.Seconds(), now)
+			}
+			continue
+		}
+
+		step := dt.Seconds() / float64(len(inputs))
+		for _, input := range inputs {
+			if player.IsAlive {
+				h.applyPlayerInput(player, input, step, now)
+			}
+			// Acknowledge consumed inputs even while dead so clients stop replaying them
+			if input.SequenceID > player.LastProcessedInput {
+				player.LastProcessedInput = input.SequenceID
+			}
+		}
+	}
+
+	h.state.LastUpdate = now
+
+	// Respawn before recording and indexing so history and the grid match the snapshot sent this tick
+	respawned := h.updateRespawns(now)
+	h.state.history.record(h.state.Tick, now, h.state.Players)
+	h.updateWeapons(now)
+	h.updateProjectiles(dt)
+	h.rebuildSpatialGrid()
+	hits := h.checkProjectileHits(dt, now)
+	h.state.mu.Unlock()
+
+	// Notify clients outside the state lock
+	for _, hit := range hits {
+		h.broadcastHit(hit)
+	}
+	for _, player := range respawned {
+		h.broadcastPlayerRespawned(player)
+	}
+}
+
+// rebuildSpatialGrid re-indexes players and projectiles at their current positions.
+// Caller must hold the GameState lock.
+func (h *GameHub) rebuildSpatialGrid() {
+	grid := h.state.grid
+	grid.Clear()
+	h.state.maxMoveSpeed = 0
+
+	for id, player := range h.state.Players {
+		grid.Insert(SpatialEntry{
+			ID:     id,
+			Kind:   EntityPlayer,
+			X:      player.PosX,
+			Y:      player.PosY,
+			Radius: h.config.PlayerRadius,
+		})
+		h.state.maxMoveSpeed = math.Max(h.state.maxMoveSpeed, player.MoveSpeed)
+	}
+
+	for id, proj := range h.state.Projectiles {
+		grid.Insert(SpatialEntry{
+			ID:     id,
+			Kind:   EntityProjectile,
+			X:      proj.PosX,
+			Y:      proj.PosY,
+			Radius: proj.Radius,
+		})
+	}
+}
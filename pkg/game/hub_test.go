@@ -0,0 +1,70 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestHub creates a hub that is not running, so a test can drive its run loop
+// methods directly. Logging is silenced for the test.
+func newTestHub(tb testing.TB, config *types.GameConfig) *GameHub {
+	tb.Helper()
+
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if config == nil {
+		config = types.GetDefaultConfig()
+	}
+	return NewGameHub(config)
+}
+
+// addClient registers a client as a new connection would, with the given features
+func addClient(h *GameHub, id, encoding string, features ...string) *types.Client {
+	client := &types.Client{
+		UUID:     id,
+		Send:     make(chan []byte, 256),
+		Snapshot: make(chan []byte, 1),
+		Ping:     make(chan types.PingMessage, 1),
+		LastSeen: time.Now(),
+		Encoding: encoding,
+		Features: make(map[string]bool),
+	}
+	for _, f := range features {
+		client.Features[f] = true
+	}
+	h.handleClientRegister(client)
+	return client
+}
+
+// placePlayer moves a player to (x, y), standing still
+func placePlayer(h *GameHub, id string, x, y float64) *types.Player {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	player := h.state.Players[id]
+	player.PosX, player.PosY = x, y
+	player.MoveX, player.MoveY = 0, 0
+	return player
+}
+
+// sentMessages empties a client's Send queue and returns its JSON messages by type
+func sentMessages(tb testing.TB, client *types.Client) map[string][]json.RawMessage {
+	tb.Helper()
+
+	msgs := make(map[string][]json.RawMessage)
+	for len(client.Send) > 0 {
+		data := <-client.Send
+		var base types.BaseMessage
+		if err := json.Unmarshal(data, &base); err != nil {
+			tb.Fatalf("message %q is not JSON: %v", data, err)
+		}
+		msgs[base.Type] = append(msgs[base.Type], data)
+	}
+	return msgs
+}
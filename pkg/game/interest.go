@@ -0,0 +1,126 @@
+package game
+
+import "game-server-v1/pkg/types"
+
+// interestSet records which entities a client currently has in view
+type interestSet struct {
+	players     map[string]bool
+	projectiles map[string]bool
+}
+
+func newInterestSet() *interestSet {
+	return &interestSet{
+		players:     make(map[string]bool),
+		projectiles: make(map[string]bool),
+	}
+}
+
+// filterSnapshot returns the part of snap relevant to a viewer: entities within the
+// interest radius of the viewer's player plus always-relevant ones. With interest
+// management disabled the snapshot is returned unchanged.
+// Caller must hold the GameState read lock so the spatial grid matches the snapshot.
+func (h *GameHub) filterSnapshot(snap *snapshot, viewerID string) *snapshot {
+	radius := h.config.InterestRadius
+	if radius <= 0 {
+		return snap
+	}
+
+	// Collect the nearby entries first so the maps can be sized once
+	var nearby []*SpatialEntry
+	players := 1 // the viewer
+	if viewer, ok := snap.players[viewerID]; ok {
+		h.state.grid.QueryRadius(viewer.PosX, viewer.PosY, radius, func(e *SpatialEntry) bool {
+			nearby = append(nearby, e)
+			if e.Kind == EntityPlayer {
+				players++
+			}
+			return true
+		})
+	}
+
+	filtered := &snapshot{
+		tick:        snap.tick,
+		time:        snap.time,
+		players:     make(map[string]types.PlayerSnapshot, players),
+		projectiles: make(map[string]types.ProjectileSnapshot, len(nearby)-players+1),
+	}
+
+	for _, e := range nearby {
+		switch e.Kind {
+		case EntityPlayer:
+			if p, ok := snap.players[e.ID]; ok {
+				filtered.players[e.ID] = p
+			}
+		case EntityProjectile:
+			if proj, ok := snap.projectiles[e.ID]; ok {
+				filtered.projectiles[e.ID] = proj
+			}
+		}
+	}
+
+	// Always-relevant entities: the viewer's own player and projectiles, plus flagged players
+	if p, ok := snap.players[viewerID]; ok {
+		filtered.players[viewerID] = p
+	}
+	for id := range h.state.alwaysRelevant {
+		if p, ok := snap.players[id]; ok {
+			filtered.players[id] = p
+		}
+	}
+	for _, id := range snap.owned[viewerID] {
+		filtered.projectiles[id] = snap.projectiles[id]
+	}
+
+	return filtered
+}
+
+// updateInterest compares a client's newly visible entities with the previous set and
+// returns the enter/leave notification, or nil when nothing crossed the boundary.
+// Entities that left the world entirely are reported through snapshot removals instead.
+func (h *GameHub) updateInterest(view *clientView, visible, world *snapshot) *types.InterestChangedMessage {
+	if h.config.InterestRadius <= 0 {
+		return nil
+	}
+
+	msg := &types.InterestChangedMessage{
+		Type: string(types.InterestChangedMsg),
+		Tick: visible.tick,
+	}
+
+	for id := range visible.players {
+		if !view.interest.players[id] {
+			view.interest.players[id] = true
+			msg.Entered = append(msg.Entered, types.EntityRef{ID: id, NetID: world.players[id].NetID, Kind: types.EntityKindPlayer})
+		}
+	}
+	for id := range view.interest.players {
+		if _, ok := visible.players[id]; ok {
+			continue
+		}
+		delete(view.interest.players, id)
+		if _, exists := world.players[id]; exists {
+			msg.Left = append(msg.Left, types.EntityRef{ID: id, NetID: world.players[id].NetID, Kind: types.EntityKindPlayer})
+		}
+	}
+
+	for id := range visible.projectiles {
+		if !view.interest.projectiles[id] {
+			view.interest.projectiles[id] = true
+			msg.Entered = append(msg.Entered, types.EntityRef{ID: id, NetID: world.projectiles[id].NetID, Kind: types.EntityKindProjectile})
+		}
+	}
+	for id := range view.interest.projectiles {
+		if _, ok := visible.projectiles[id]; ok {
+			continue
+		}
+		delete(view.interest.projectiles, id)
+		if _, exists := world.projectiles[id]; exists {
+			msg.Left = append(msg.Left, types.EntityRef{ID: id, NetID: world.projectiles[id].NetID, Kind: types.EntityKindProjectile})
+		}
+	}
+
+	if len(msg.Entered) == 0 && len(msg.Left) == 0 {
+		return nil
+	}
+	return msg
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"testing"
+)
+
+// broadcastTick indexes the players where they stand and sends everyone a snapshot
+func broadcastTick(h *GameHub) {
+	h.state.mu.Lock()
+	h.state.Tick++
+	h.rebuildSpatialGrid()
+	h.state.mu.Unlock()
+	h.broadcastGameState(h.snapshotState())
+}
+
+// interestChanges returns the interestChanged messages a client was sent
+func interestChanges(t *testing.T, client *types.Client) []types.InterestChangedMessage {
+	t.Helper()
+	var changes []types.InterestChangedMessage
+	for _, data := range sentMessages(t, client)[string(types.InterestChangedMsg)] {
+		var msg types.InterestChangedMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, msg)
+	}
+	return changes
+}
+
+func TestInterestEnterAndLeave(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.InterestRadius = 20
+	h := newTestHub(t, config)
+	me := addClient(h, "me", types.EncodingJSON, types.FeatureInterestEvents)
+	plain := addClient(h, "plain", types.EncodingJSON)
+	addClient(h, "other", types.EncodingJSON)
+	placePlayer(h, "me", 0, 0)
+	placePlayer(h, "plain", 0, 30)
+	other := placePlayer(h, "other", 50, 0)
+
+	// Settle the interest sets players got at their spawn points
+	broadcastTick(h)
+	sentMessages(t, me)
+	sentMessages(t, plain)
+
+	// Each step moves the other player and lists what should cross the boundary
+	steps := []struct {
+		name        string
+		otherX      float64
+		wantEntered []string
+		wantLeft    []string
+	}{
+		{"comes into range", 10, []string{"other"}, nil},
+		{"stays in range", 15, nil, nil},
+		{"goes out of range", 45, nil, []string{"other"}},
+		{"stays away", 60, nil, nil},
+	}
+
+	for _, step := range steps {
+		placePlayer(h, "other", step.otherX, 0)
+		broadcastTick(h)
+
+		changes := interestChanges(t, me)
+		if step.wantEntered == nil && step.wantLeft == nil {
+			if len(changes) != 0 {
+				t.Errorf("%s: notified %+v, want nothing", step.name, changes)
+			}
+			continue
+		}
+		if len(changes) != 1 {
+			t.Fatalf("%s: got %d notifications, want 1", step.name, len(changes))
+		}
+		change := changes[0]
+		if change.Tick != h.state.Tick {
+			t.Errorf("%s: tick %d, want %d", step.name, change.Tick, h.state.Tick)
+		}
+		if !sameRefs(change.Entered, step.wantEntered) || !sameRefs(change.Left, step.wantLeft) {
+			t.Errorf("%s: entered %+v, left %+v; want entered %v, left %v", step.name, change.Entered, change.Left, step.wantEntered, step.wantLeft)
+		}
+		for _, ref := range append(change.Entered, change.Left...) {
+			if ref.ID == other.ID && (ref.NetID != other.NetID || ref.Kind != types.EntityKindPlayer) {
+				t.Errorf("%s: reference %+v does not identify the other player", step.name, ref)
+			}
+		}
+	}
+
+	if changes := interestChanges(t, plain); len(changes) != 0 {
+		t.Errorf("client without interest events was notified: %+v", changes)
+	}
+}
+
+// sameRefs reports whether refs name exactly the given ids
+func sameRefs(refs []types.EntityRef, ids []string) bool {
+	if len(refs) != len(ids) {
+		return false
+	}
+	for i, ref := range refs {
+		if ref.ID != ids[i] {
+			return false
+		}
+	}
+	return true
+}
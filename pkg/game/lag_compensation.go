@@ -0,0 +1,137 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"time"
+)
+
+// playerPosition is a player's recorded location at one tick
+type playerPosition struct {
+	X, Y float64
+}
+
+// historyFrame holds every player's position at the end of a tick
+type historyFrame struct {
+	tick      uint64
+	time      time.Time
+	positions map[string]playerPosition
+}
+
+// positionHistory is a ring buffer of past player positions used to rewind hit tests
+type positionHistory struct {
+	frames []historyFrame
+	next   int
+	count  int
+}
+
+// newPositionHistory creates a history large enough to rewind maxRewind at the given tick interval
+func newPositionHistory(maxRewind, tickInterval time.Duration) *positionHistory {
+	size := 2
+	if tickInterval > 0 {
+		size += int(maxRewind / tickInterval)
+	}
+	return &positionHistory{frames: make([]historyFrame, size)}
+}
+
+// record stores the current player positions, overwriting the oldest frame when full
+func (ph *positionHistory) record(tick uint64, now time.Time, players map[string]*types.Player) {
+	frame := &ph.frames[ph.next]
+	frame.tick = tick
+	frame.time = now
+	if frame.positions == nil {
+		frame.positions = make(map[string]playerPosition, len(players))
+	} else {
+		clear(frame.positions)
+	}
+	for id, p := range players {
+		frame.positions[id] = playerPosition{X: p.PosX, Y: p.PosY}
+	}
+
+	ph.next = (ph.next + 1) % len(ph.frames)
+	if ph.count < len(ph.frames) {
+		ph.count++
+	}
+}
+
+// frame returns the i-th recorded frame, 0 being the oldest
+func (ph *positionHistory) frame(i int) *historyFrame {
+	start := (ph.next - ph.count + len(ph.frames)) % len(ph.frames)
+	return &ph.frames[(start+i)%len(ph.frames)]
+}
+
+// positionAt returns where a player was at time t, interpolating between recorded ticks.
+// Times before the oldest frame clamp to it; ok is false if the player was never recorded.
+func (ph *positionHistory) positionAt(id string, t time.Time) (playerPosition, bool) {
+	var prev *historyFrame
+	for i := 0; i < ph.count; i++ {
+		cur := ph.frame(i)
+		pos, ok := cur.positions[id]
+		if !ok {
+			prev = nil
+			continue
+		}
+
+		if !cur.time.Before(t) {
+			if prev == nil {
+				return pos, true
+			}
+			before := prev.positions[id]
+			span := cur.time.Sub(prev.time)
+			if span <= 0 {
+				return pos, true
+			}
+			alpha := float64(t.Sub(prev.time)) / float64(span)
+			return playerPosition{
+				X: before.X + (pos.X-before.X)*alpha,
+				Y: before.Y + (pos.Y-before.Y)*alpha,
+			}, true
+		}
+		prev = cur
+	}
+
+	if prev != nil {
+		return prev.positions[id], true
+	}
+	return playerPosition{}, false
+}
+
+// rewindDuration returns how far behind the server the shooter's view was when firing.
+// The shot's timestamp says which server time the client was rendering; without one
+// it is estimated from the RTT. Once the RTT is measured, a timestamp cannot claim a
+// view older than a round trip plus interpolation, and the result is always bounded
+// by the configured maximum rewind window.
+func (h *GameHub) rewindDuration(client *types.Client, timestamp float64, now time.Time) time.Duration {
+	estimate := client.RTT/2 + h.config.InterpolationDelay
+
+	rewind := estimate
+	if timestamp > 0 {
+		rewind = now.Sub(time.Unix(0, int64(timestamp*1e9)))
+		if limit := client.RTT + h.config.InterpolationDelay + h.config.TickInterval; client.RTT > 0 && rewind > limit {
+			rewind = limit
+		}
+	}
+
+	if rewind > h.config.MaxRewind {
+		rewind = h.config.MaxRewind
+	}
+	if rewind < 0 {
+		rewind = 0
+	}
+	return rewind
+}
+
+// rewoundPosition returns where the shooter saw a player, falling back to the current position.
+// A rewind never reaches past the player's last spawn into a previous life.
+// Caller must hold the GameState lock.
+func (h *GameHub) rewoundPosition(player *types.Player, rewind time.Duration) playerPosition {
+	if rewind > 0 {
+		at := h.state.LastUpdate.Add(-rewind)
+		if at.Before(player.SpawnedAt) {
+			at = player.SpawnedAt
+		}
+		if pos, ok := h.state.history.positionAt(player.ID, at); ok {
+			return pos
+		}
+	}
+	return playerPosition{X: player.PosX, Y: player.PosY}
+}
@@ -0,0 +1,139 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"testing"
+	"time"
+)
+
+func TestRewindDuration(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.TickInterval = 50 * time.Millisecond
+	config.InterpolationDelay = 100 * time.Millisecond
+	config.MaxRewind = 500 * time.Millisecond
+	h := newTestHub(t, config)
+
+	now := time.Now()
+	// sentAgo is the shot timestamp a client rendering d behind the server would send
+	sentAgo := func(d time.Duration) float64 {
+		return float64(now.Add(-d).UnixNano()) / 1e9
+	}
+
+	tests := []struct {
+		name      string
+		rtt       time.Duration
+		timestamp float64
+		want      time.Duration
+	}{
+		{"estimated from RTT without a timestamp", 60 * time.Millisecond, 0, 130 * time.Millisecond},
+		{"timestamp within the limit", 40 * time.Millisecond, sentAgo(150 * time.Millisecond), 150 * time.Millisecond},
+		{"timestamp clamped to RTT plus interpolation and a tick", 40 * time.Millisecond, sentAgo(400 * time.Millisecond), 190 * time.Millisecond},
+		{"timestamp trusted until RTT is measured", 0, sentAgo(300 * time.Millisecond), 300 * time.Millisecond},
+		{"timestamp clamped to MaxRewind", 0, sentAgo(2 * time.Second), 500 * time.Millisecond},
+		{"estimate clamped to MaxRewind", time.Second, 0, 500 * time.Millisecond},
+		{"timestamp from the future", 40 * time.Millisecond, sentAgo(-time.Second), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &types.Client{RTT: tt.rtt}
+			got := h.rewindDuration(client, tt.timestamp, now)
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("rewindDuration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordPath records one frame per tick with the player at x = 0, 1, 2, ... and returns the frame times
+func recordPath(ph *positionHistory, id string, ticks int, tickInterval time.Duration) []time.Time {
+	start := time.Now()
+	player := &types.Player{ID: id}
+	players := map[string]*types.Player{id: player}
+
+	times := make([]time.Time, ticks)
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * tickInterval)
+		player.PosX = float64(i)
+		ph.record(uint64(i), times[i], players)
+	}
+	return times
+}
+
+func TestPositionAt(t *testing.T) {
+	const tick = 50 * time.Millisecond
+	ph := newPositionHistory(time.Second, tick)
+	times := recordPath(ph, "p1", 5, tick)
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		wantX float64
+	}{
+		{"on a frame", times[2], 2},
+		{"between frames", times[2].Add(tick / 4), 2.25},
+		{"before the oldest frame", times[0].Add(-time.Second), 0},
+		{"after the newest frame", times[4].Add(time.Second), 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, ok := ph.positionAt("p1", tt.at)
+			if !ok || pos.X != tt.wantX {
+				t.Errorf("positionAt = %+v, %v, want x=%g", pos, ok, tt.wantX)
+			}
+		})
+	}
+
+	if _, ok := ph.positionAt("unknown", times[2]); ok {
+		t.Error("positionAt found a player that was never recorded")
+	}
+}
+
+func TestPositionHistoryWraps(t *testing.T) {
+	const tick = 50 * time.Millisecond
+	ph := newPositionHistory(2*tick, tick) // room for 4 frames
+	times := recordPath(ph, "p1", 10, tick)
+
+	if ph.count != len(ph.frames) {
+		t.Fatalf("count = %d, want a full buffer of %d", ph.count, len(ph.frames))
+	}
+	if got := ph.frame(0).tick; got != 6 {
+		t.Errorf("oldest frame is tick %d, want 6", got)
+	}
+
+	// The overwritten ticks clamp to the oldest kept frame
+	if pos, _ := ph.positionAt("p1", times[1]); pos.X != 6 {
+		t.Errorf("position at an overwritten tick: x=%g, want 6", pos.X)
+	}
+	if pos, _ := ph.positionAt("p1", times[8].Add(tick/2)); pos.X != 8.5 {
+		t.Errorf("position across the wrap: x=%g, want 8.5", pos.X)
+	}
+}
+
+func TestRewindStopsAtRespawn(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.SpawnProtection = 0
+	config.RespawnDelay = 0
+	h := newTestHub(t, config)
+	addClient(h, "p1", types.EncodingJSON)
+	player := placePlayer(h, "p1", 1, 1)
+
+	for i := 0; i < 3; i++ {
+		h.updateGameState()
+	}
+
+	// Die where the history recorded the player, then respawn on the next tick
+	h.state.mu.Lock()
+	player.IsAlive = false
+	player.DiedAt = time.Now().Add(-time.Second)
+	h.state.mu.Unlock()
+	h.updateGameState()
+
+	if !player.IsAlive || (player.PosX == 1 && player.PosY == 1) {
+		t.Fatalf("player did not respawn elsewhere: alive=%v at (%g, %g)", player.IsAlive, player.PosX, player.PosY)
+	}
+	if pos := h.rewoundPosition(player, h.config.MaxRewind); pos.X != player.PosX || pos.Y != player.PosY {
+		t.Errorf("rewind reached the previous life at (%g, %g), want the spawn (%g, %g)", pos.X, pos.Y, player.PosX, player.PosY)
+	}
+}
@@ -3,52 +3,102 @@ package game
 import (
 	// "encoding/json"
 	"game-server-v1/pkg/types"
-	"log"
+	"math"
 	"time"
 )
 
-// HandlePlayerMovement processes input from a client and updates the central GameState
-func UpdatePlayerMovement(hub *GameHub, client *types.Client, input *types.PlayerInputMessage) {
-	hub.state.mu.Lock()
-	defer hub.state.mu.Unlock()
+// maxBufferedInputs caps how many inputs a player can queue between two ticks.
+// The playerInput rate limit keeps a well-behaved client far below it.
+const maxBufferedInputs = 32
 
-	// Ensure client has an associated player
-	if client.Player == nil {
-		log.Printf("Client %s has no associated player", client.UUID)
+// inputBuffer holds the inputs received for one player that have not been simulated yet
+type inputBuffer struct {
+	pending     []*types.PlayerInputMessage
+	spare       []*types.PlayerInputMessage // the slice take returned last, reused by the next one
+	last        *types.PlayerInputMessage
+	lastSeq     int64
+	repeatTicks int
+}
+
+// push queues an input, dropping duplicates, stale sequence IDs and the oldest entry on overflow
+func (b *inputBuffer) push(input *types.PlayerInputMessage) {
+	if input.SequenceID > 0 && input.SequenceID <= b.lastSeq {
 		return
 	}
+	if input.SequenceID > 0 {
+		b.lastSeq = input.SequenceID
+	}
 
-	playerID := client.Player.ID
-	player, ok := hub.state.Players[playerID]
-	if !ok {
-		log.Printf("Player not found in GameState: %s", playerID)
-		return
+	if len(b.pending) >= maxBufferedInputs {
+		b.pending = b.pending[1:]
+	}
+	b.pending = append(b.pending, input)
+}
+
+// take returns the inputs to simulate this tick, oldest first. Everything received
+// since the last tick is consumed so a client sending faster than the tick rate
+// does not build up latency; the caller splits the tick between the inputs. When
+// nothing arrived the last input is repeated for up to maxRepeat ticks, after
+// which take returns nil and the player stops. The returned slice is only valid
+// until the next take.
+func (b *inputBuffer) take(maxRepeat int) []*types.PlayerInputMessage {
+	if n := len(b.pending); n > 0 {
+		inputs := b.pending
+		clear(b.spare)
+		b.pending, b.spare = b.spare[:0], inputs
+		b.last = inputs[n-1]
+		b.repeatTicks = 0
+		return inputs
+	}
+
+	if b.last != nil && b.repeatTicks < maxRepeat {
+		b.repeatTicks++
+		b.spare = append(b.spare[:0], b.last)
+		return b.spare
 	}
 
-	// Validate input (anti-cheat sanity check)
-	if input.MoveX < -1 || input.MoveX > 1 || input.MoveY < -1 || input.MoveY > 1 {
-		log.Printf("Invalid movement input from %s", playerID)
+	return nil
+}
+
+// reset discards queued and repeated inputs but keeps the sequence high-water mark
+func (b *inputBuffer) reset() {
+	b.pending = nil
+	b.spare = nil
+	b.last = nil
+	b.repeatTicks = 0
+}
+
+// applyPlayerInput moves a player for a single tick. A nil input stops the player.
+// Caller must hold the GameState lock.
+func (h *GameHub) applyPlayerInput(player *types.Player, input *types.PlayerInputMessage, dt float64, now time.Time) {
+	if input == nil {
+		player.MoveX = 0
+		player.MoveY = 0
 		return
 	}
 
+	moveX, moveY := input.MoveX, input.MoveY
+
+	// Normalize diagonals so they are not faster than straight movement
+	if length := math.Hypot(moveX, moveY); length > 1 {
+		moveX /= length
+		moveY /= length
+	}
+
 	// Calculate new position
-	deltaTime := hub.config.TickInterval.Seconds()
-	newX := player.PosX + input.MoveX*hub.config.MoveSpeed*deltaTime
-	newY := player.PosY + input.MoveY*hub.config.MoveSpeed*deltaTime
+	newX := player.PosX + moveX*player.MoveSpeed*dt
+	newY := player.PosY + moveY*player.MoveSpeed*dt
 
-	// Clamp position inside world bounds
-	newX, newY = hub.config.WorldBounds.ClampPosition(newX, newY)
+	// Slide along obstacles and stay inside world bounds
+	newX, newY = h.world.ResolveCircle(newX, newY, h.config.PlayerRadius)
 
 	// Update authoritative player state in GameState
 	player.PosX = newX
 	player.PosY = newY
-	player.MoveX = input.MoveX
-	player.MoveY = input.MoveY
+	player.MoveX = moveX
+	player.MoveY = moveY
 	player.FacingLeft = input.FacingLeft
-	player.LastUpdate = time.Now()
-
-	// Update global GameState timestamp
-	hub.state.LastUpdate = time.Now()
+	player.LastUpdate = now
 }
 
 // // broadcastPlayerState sends updated player state to all connected clients
@@ -0,0 +1,122 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"math"
+	"testing"
+)
+
+// queueInputs hands inputs for a player to the hub as the run loop would
+func queueInputs(h *GameHub, id string, firstSeq int64, moves ...[2]float64) {
+	for i, m := range moves {
+		h.handlePlayerInput(&types.PlayerInputMessage{
+			Type:       string(types.PlayerInputMsg),
+			PlayerID:   id,
+			MoveX:      m[0],
+			MoveY:      m[1],
+			SequenceID: firstSeq + int64(i),
+		})
+	}
+}
+
+func TestInputsWithinOneTickAreAllSimulated(t *testing.T) {
+	h := newTestHub(t, nil)
+	addClient(h, "p1", types.EncodingJSON)
+	player := placePlayer(h, "p1", 0, 0)
+	tickMove := player.MoveSpeed * h.config.TickInterval.Seconds()
+
+	tests := []struct {
+		name  string
+		moves [][2]float64
+		wantX float64
+	}{
+		{"one input moves a whole tick", [][2]float64{{1, 0}}, tickMove},
+		{"inputs share the tick", [][2]float64{{1, 0}, {1, 0}, {-1, 0}}, tickMove / 3},
+		{"many inputs never exceed one tick", [][2]float64{{1, 0}, {1, 0}, {1, 0}, {1, 0}, {1, 0}, {1, 0}}, tickMove},
+	}
+
+	seq := int64(1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			placePlayer(h, "p1", 0, 0)
+			queueInputs(h, "p1", seq, tt.moves...)
+			seq += int64(len(tt.moves))
+
+			h.updateGameState()
+
+			if math.Abs(player.PosX-tt.wantX) > 1e-9 || player.PosY != 0 {
+				t.Errorf("moved to (%g, %g), want (%g, 0)", player.PosX, player.PosY, tt.wantX)
+			}
+			if player.LastProcessedInput != seq-1 {
+				t.Errorf("LastProcessedInput = %d, want the last simulated input %d", player.LastProcessedInput, seq-1)
+			}
+			if want := tt.moves[len(tt.moves)-1][0]; player.MoveX != want {
+				t.Errorf("MoveX = %g, want the last input's %g", player.MoveX, want)
+			}
+		})
+	}
+}
+
+func TestRepeatedInputIsNotAcknowledgedAgain(t *testing.T) {
+	h := newTestHub(t, nil)
+	addClient(h, "p1", types.EncodingJSON)
+	player := placePlayer(h, "p1", 0, 0)
+	tickMove := player.MoveSpeed * h.config.TickInterval.Seconds()
+
+	queueInputs(h, "p1", 7, [2]float64{1, 0})
+	h.updateGameState()
+
+	// Without new input the last one keeps the player moving for InputRepeatTicks, then it stops
+	for i := 0; i < h.config.InputRepeatTicks+2; i++ {
+		h.updateGameState()
+	}
+	want := tickMove * float64(1+h.config.InputRepeatTicks)
+	if math.Abs(player.PosX-want) > 1e-9 {
+		t.Errorf("moved to x=%g, want %g", player.PosX, want)
+	}
+	if player.MoveX != 0 {
+		t.Errorf("still moving with MoveX=%g after the repeat ran out", player.MoveX)
+	}
+	if player.LastProcessedInput != 7 {
+		t.Errorf("LastProcessedInput = %d, want 7", player.LastProcessedInput)
+	}
+}
+
+func TestStaleInputsAreDropped(t *testing.T) {
+	h := newTestHub(t, nil)
+	addClient(h, "p1", types.EncodingJSON)
+	player := placePlayer(h, "p1", 0, 0)
+
+	queueInputs(h, "p1", 5, [2]float64{1, 0})
+	queueInputs(h, "p1", 4, [2]float64{-1, 0}, [2]float64{-1, 0}) // sequence 4 and a duplicate 5
+	h.updateGameState()
+
+	if player.PosX <= 0 {
+		t.Errorf("stale inputs were simulated: x=%g", player.PosX)
+	}
+	if player.LastProcessedInput != 5 {
+		t.Errorf("LastProcessedInput = %d, want 5", player.LastProcessedInput)
+	}
+}
+
+func TestSnapshotAcknowledgesLastSimulatedInput(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON)
+	placePlayer(h, "p1", 0, 0)
+
+	queueInputs(h, "p1", 11, [2]float64{1, 0}, [2]float64{0, 1}, [2]float64{-1, 0})
+	h.updateGameState()
+	h.broadcastGameState(h.snapshotState())
+
+	var msg types.GameStateMessage
+	if err := json.Unmarshal(<-client.Snapshot, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Tick != h.state.Tick {
+		t.Errorf("snapshot tick = %d, want %d", msg.Tick, h.state.Tick)
+	}
+	if got := msg.Players["p1"].LastProcessedInput; got != 13 {
+		t.Errorf("LastProcessedInput = %d, want the last simulated input 13", got)
+	}
+}
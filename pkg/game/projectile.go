@@ -2,29 +2,165 @@ package game
 
 import (
 	"game-server-v1/pkg/types"
+	"log"
+	"math"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-type Projectile struct {
-	ID        string
-	PlayerID  string
-	PosX      float64
-	PosY      float64
-	DirX      float64
-	DirY      float64
-	Speed     float64
-	CreatedAt time.Time
+// NewProjectile creates a projectile fired by a player's weapon along a unit direction
+func NewProjectile(owner *types.Player, weapon types.WeaponConfig, dirX, dirY float64, now time.Time) *types.Projectile {
+	return &types.Projectile{
+		ID:        uuid.New().String(),
+		OwnerID:   owner.ID,
+		PosX:      owner.PosX,
+		PosY:      owner.PosY,
+		VelX:      dirX * weapon.ProjectileSpeed,
+		VelY:      dirY * weapon.ProjectileSpeed,
+		CreatedAt: now,
+		Lifetime:  weapon.Lifetime,
+		Radius:    weapon.Radius,
+		Damage:    weapon.Damage,
+	}
+}
+
+// projectileHit records a projectile striking a player during a tick
+type projectileHit struct {
+	ProjectileID string
+	AttackerID   string
+	VictimID     string
+	Damage       int
+	Health       int
+	Killed       bool
+
+	// Net IDs for the binary protocol; the attacker's is zero if they already left
+	ProjectileNetID uint32
+	AttackerNetID   uint32
+	VictimNetID     uint32
+}
+
+// updateProjectiles moves projectiles and removes expired, out-of-bounds or obstructed ones.
+// Caller must hold the GameState lock.
+func (h *GameHub) updateProjectiles(dt time.Duration) {
+	bounds := h.world.Bounds
+
+	for id, proj := range h.state.Projectiles {
+		proj.PosX += proj.VelX * dt.Seconds()
+		proj.PosY += proj.VelY * dt.Seconds()
+		proj.Lifetime -= dt
+
+		if proj.Lifetime <= 0 || !bounds.Contains(proj.PosX, proj.PosY) ||
+			h.world.OverlapsCircle(proj.PosX, proj.PosY, proj.Radius) {
+			delete(h.state.Projectiles, id)
+		}
+	}
+}
+
+// checkProjectileHits applies damage for projectiles that touched a living player
+// on their way through the last tick of length dt. The whole path is tested, so a
+// fast projectile cannot skip over a player between ticks. Each projectile hits
+// at most one player, the first along its path, and is removed on impact.
+// Caller must hold the GameState lock.
+func (h *GameHub) checkProjectileHits(dt time.Duration, now time.Time) []projectileHit {
+	var hits []projectileHit
+
+	for id, proj := range h.state.Projectiles {
+		reach := proj.Radius + h.config.PlayerRadius
+
+		// The path this tick, ending at the current position
+		fromX, fromY := proj.PosX-proj.VelX*dt.Seconds(), proj.PosY-proj.VelY*dt.Seconds()
+		midX, midY := (fromX+proj.PosX)/2, (fromY+proj.PosY)/2
+		halfPath := math.Hypot(proj.PosX-fromX, proj.PosY-fromY) / 2
+
+		// Rewound targets can be away from their current position by as far as they move in the rewind window
+		margin := h.state.maxMoveSpeed * proj.Rewind.Seconds()
+
+		var target *types.Player
+		first := math.Inf(1)
+		h.state.grid.QueryRadius(midX, midY, halfPath+proj.Radius+margin, func(e *SpatialEntry) bool {
+			if e.Kind != EntityPlayer {
+				return true
+			}
+			player, ok := h.state.Players[e.ID]
+			if !ok || player.ID == proj.OwnerID || !player.IsAlive || player.Invulnerable {
+				return true
+			}
+
+			// Test against where the shooter saw the target
+			pos := h.rewoundPosition(player, proj.Rewind)
+			if dist, along := segmentDistance(pos.X, pos.Y, fromX, fromY, proj.PosX, proj.PosY); dist <= reach && along < first {
+				target = player
+				first = along
+			}
+			return true
+		})
+		if target == nil {
+			continue
+		}
+
+		target.Health -= proj.Damage
+		if target.Health <= 0 {
+			target.Health = 0
+			target.IsAlive = false
+			target.DiedAt = now
+			target.MoveX = 0
+			target.MoveY = 0
+		}
+
+		hit := projectileHit{
+			ProjectileID:    id,
+			AttackerID:      proj.OwnerID,
+			VictimID:        target.ID,
+			Damage:          proj.Damage,
+			Health:          target.Health,
+			Killed:          !target.IsAlive,
+			ProjectileNetID: proj.NetID,
+			VictimNetID:     target.NetID,
+		}
+		if attacker, ok := h.state.Players[proj.OwnerID]; ok {
+			hit.AttackerNetID = attacker.NetID
+		}
+		hits = append(hits, hit)
+
+		delete(h.state.Projectiles, id)
+	}
+
+	return hits
+}
+
+// segmentDistance returns how close the segment from (ax, ay) to (bx, by) passes to
+// (px, py), and how far along the segment, from 0 to 1, the closest point lies
+func segmentDistance(px, py, ax, ay, bx, by float64) (dist, along float64) {
+	dx, dy := bx-ax, by-ay
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		along = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lengthSq))
+	}
+	return math.Hypot(ax+dx*along-px, ay+dy*along-py), along
 }
 
-func NewProjectile(msg types.ProjectileMessage) *Projectile {
-	return &Projectile{
-		ID:        msg.ProjectileID,
-		PlayerID:  msg.PlayerID,
-		PosX:      msg.PosX,
-		PosY:      msg.PosY,
-		DirX:      msg.DirX,
-		DirY:      msg.DirY,
-		Speed:     msg.Speed,
-		CreatedAt: time.Now(),
+// broadcastHit announces damage and, if fatal, the kill to all clients
+func (h *GameHub) broadcastHit(hit projectileHit) {
+	h.broadcastEncoded(types.PlayerDamagedMessage{
+		Type:            string(types.PlayerDamagedMsg),
+		PlayerID:        hit.VictimID,
+		NetID:           hit.VictimNetID,
+		AttackerID:      hit.AttackerID,
+		AttackerNetID:   hit.AttackerNetID,
+		ProjectileID:    hit.ProjectileID,
+		ProjectileNetID: hit.ProjectileNetID,
+		Damage:          hit.Damage,
+		Health:          hit.Health,
+	}, encodeHitBinary(hit))
+
+	if hit.Killed {
+		log.Printf("Player %s killed by %s", hit.VictimID, hit.AttackerID)
+		h.publishEvent(types.PlayerKilledMessage{
+			Type:        string(types.PlayerKilledMsg),
+			PlayerID:    hit.VictimID,
+			NetID:       hit.VictimNetID,
+			KillerID:    hit.AttackerID,
+			KillerNetID: hit.AttackerNetID,
+		}, encodeKillBinary(hit))
 	}
 }
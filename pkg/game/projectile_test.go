@@ -0,0 +1,190 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"testing"
+	"time"
+)
+
+// newCombatHub creates a hub with players spawning unprotected
+func newCombatHub(t *testing.T) *GameHub {
+	t.Helper()
+	config := types.GetDefaultConfig()
+	config.SpawnProtection = 0
+	return newTestHub(t, config)
+}
+
+// addProjectile puts a projectile from owner at (x, y), where it arrived this tick at the given velocity
+func addProjectile(h *GameHub, owner *types.Player, x, y, velX, velY float64, damage int) *types.Projectile {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	proj := NewProjectile(owner, types.WeaponConfig{Radius: 0.25, Lifetime: time.Second, Damage: damage}, 0, 0, time.Now())
+	proj.NetID = h.allocNetID()
+	proj.PosX, proj.PosY = x, y
+	proj.VelX, proj.VelY = velX, velY
+	h.state.Projectiles[proj.ID] = proj
+	return proj
+}
+
+// resolveHits runs the hit check for the tick that just moved every projectile
+func resolveHits(h *GameHub) []projectileHit {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.rebuildSpatialGrid()
+	return h.checkProjectileHits(h.config.TickInterval, time.Now())
+}
+
+func TestCheckProjectileHits(t *testing.T) {
+	tests := []struct {
+		name         string
+		x, y         float64 // projectile position after its move
+		velX         float64
+		health       int
+		invulnerable bool
+		fromOwner    bool // the victim fired it
+
+		wantHit    bool
+		wantHealth int
+		wantKilled bool
+	}{
+		{name: "hit", x: 0, y: 0, velX: 20, health: 100, wantHit: true, wantHealth: 90},
+		{name: "grazing hit", x: 0, y: 0.7, velX: 20, health: 100, wantHit: true, wantHealth: 90},
+		{name: "miss", x: 0, y: 3, velX: 20, health: 100, wantHealth: 100},
+		{name: "not yet reached", x: -2, y: 0, velX: 20, health: 100, wantHealth: 100},
+		{name: "spawn protected", x: 0, y: 0, velX: 20, health: 100, invulnerable: true, wantHealth: 100},
+		{name: "own projectile", x: 0, y: 0, velX: 20, health: 100, fromOwner: true, wantHealth: 100},
+		{name: "kill", x: 0, y: 0, velX: 20, health: 5, wantHit: true, wantHealth: 0, wantKilled: true},
+		{name: "swept past between ticks", x: 5, y: 0, velX: 300, health: 100, wantHit: true, wantHealth: 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newCombatHub(t)
+			addClient(h, "attacker", types.EncodingJSON)
+			addClient(h, "victim", types.EncodingJSON)
+			attacker := placePlayer(h, "attacker", -20, 0)
+			victim := placePlayer(h, "victim", 0, 0)
+			victim.Health = tt.health
+			victim.Invulnerable = tt.invulnerable
+
+			owner := attacker
+			if tt.fromOwner {
+				owner = victim
+			}
+			proj := addProjectile(h, owner, tt.x, tt.y, tt.velX, 0, 10)
+
+			hits := resolveHits(h)
+
+			if got := len(hits) == 1; got != tt.wantHit || len(hits) > 1 {
+				t.Fatalf("got %d hits, want hit=%v", len(hits), tt.wantHit)
+			}
+			if victim.Health != tt.wantHealth {
+				t.Errorf("victim health = %d, want %d", victim.Health, tt.wantHealth)
+			}
+			if victim.IsAlive == tt.wantKilled {
+				t.Errorf("victim alive = %v, want %v", victim.IsAlive, !tt.wantKilled)
+			}
+			if _, remains := h.state.Projectiles[proj.ID]; remains == tt.wantHit {
+				t.Errorf("projectile remains = %v after hit = %v", remains, tt.wantHit)
+			}
+			if !tt.wantHit {
+				return
+			}
+
+			hit := hits[0]
+			want := projectileHit{
+				ProjectileID:    proj.ID,
+				AttackerID:      attacker.ID,
+				VictimID:        victim.ID,
+				Damage:          10,
+				Health:          tt.wantHealth,
+				Killed:          tt.wantKilled,
+				ProjectileNetID: proj.NetID,
+				AttackerNetID:   attacker.NetID,
+				VictimNetID:     victim.NetID,
+			}
+			if hit != want {
+				t.Errorf("hit = %+v, want %+v", hit, want)
+			}
+			if tt.wantKilled && victim.DiedAt.IsZero() {
+				t.Error("DiedAt not set on a kill")
+			}
+		})
+	}
+}
+
+func TestProjectileHitsFirstPlayerOnItsPath(t *testing.T) {
+	h := newCombatHub(t)
+	addClient(h, "attacker", types.EncodingJSON)
+	addClient(h, "near", types.EncodingJSON)
+	addClient(h, "far", types.EncodingJSON)
+	attacker := placePlayer(h, "attacker", -20, 0)
+	near := placePlayer(h, "near", -2, 0)
+	far := placePlayer(h, "far", 2, 0)
+
+	// Travels from (-5, 0) to (5, 0) in one tick, crossing both
+	addProjectile(h, attacker, 5, 0, 10/h.config.TickInterval.Seconds(), 0, 10)
+
+	hits := resolveHits(h)
+	if len(hits) != 1 || hits[0].VictimID != near.ID {
+		t.Fatalf("hits = %+v, want one hit on %s", hits, near.ID)
+	}
+	if far.Health != far.MaxHealth {
+		t.Errorf("player behind the first victim took damage: health %d", far.Health)
+	}
+}
+
+func TestBroadcastHitAnnouncesKill(t *testing.T) {
+	h := newCombatHub(t)
+	plain := addClient(h, "plain", types.EncodingJSON)
+	reliable := addClient(h, "reliable", types.EncodingJSON, types.FeatureReliableEvents)
+	sentMessages(t, plain)
+	sentMessages(t, reliable)
+
+	hit := projectileHit{ProjectileID: "p", AttackerID: "plain", VictimID: "reliable", Damage: 10, Health: 0, Killed: true}
+	h.broadcastHit(hit)
+
+	msgs := sentMessages(t, plain)
+	if len(msgs[string(types.PlayerDamagedMsg)]) != 1 || len(msgs[string(types.PlayerKilledMsg)]) != 1 {
+		t.Fatalf("plain client got %v, want one playerDamaged and one playerKilled", keys(msgs))
+	}
+	var killed types.PlayerKilledMessage
+	if err := json.Unmarshal(msgs[string(types.PlayerKilledMsg)][0], &killed); err != nil {
+		t.Fatal(err)
+	}
+	if killed.PlayerID != "reliable" || killed.KillerID != "plain" {
+		t.Errorf("playerKilled = %+v", killed)
+	}
+
+	// Reliable clients get the kill sequenced in an event
+	msgs = sentMessages(t, reliable)
+	if len(msgs[string(types.PlayerDamagedMsg)]) != 1 || len(msgs[string(types.EventMsg)]) != 1 {
+		t.Fatalf("reliable client got %v, want one playerDamaged and one event", keys(msgs))
+	}
+	var event types.EventMessage
+	if err := json.Unmarshal(msgs[string(types.EventMsg)][0], &event); err != nil {
+		t.Fatal(err)
+	}
+	var inner types.BaseMessage
+	if err := json.Unmarshal(event.Event, &inner); err != nil || inner.Type != string(types.PlayerKilledMsg) {
+		t.Errorf("event carries %s, want %s", event.Event, types.PlayerKilledMsg)
+	}
+
+	// A hit that does not kill announces only the damage
+	h.broadcastHit(projectileHit{ProjectileID: "q", AttackerID: "plain", VictimID: "reliable", Damage: 10, Health: 50})
+	if msgs := sentMessages(t, plain); len(msgs[string(types.PlayerKilledMsg)]) != 0 {
+		t.Error("a non-fatal hit announced a kill")
+	}
+}
+
+// keys lists the message types received, for failure messages
+func keys(msgs map[string][]json.RawMessage) []string {
+	var names []string
+	for name := range msgs {
+		names = append(names, name)
+	}
+	return names
+}
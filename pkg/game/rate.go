@@ -0,0 +1,53 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"log"
+)
+
+// snapshotRate is the rate a client currently gets snapshots at
+func (h *GameHub) snapshotRate(client *types.Client) int {
+	rate := h.config.MaxSnapshotRate()
+	if client.SnapshotRate > 0 && client.SnapshotRate < rate {
+		rate = client.SnapshotRate
+	}
+	return rate
+}
+
+// snapshotDue reports whether a client is owed a snapshot this tick. Each tick earns
+// the client its share of a snapshot, so rates that do not divide the tick rate
+// still average out exactly. Credit is counted in whole ticks' shares so it never drifts.
+func (h *GameHub) snapshotDue(client *types.Client, view *clientView) bool {
+	view.snapshotCredit += h.snapshotRate(client)
+	if view.snapshotCredit < h.config.TickRate {
+		return false
+	}
+	view.snapshotCredit -= h.config.TickRate
+	return true
+}
+
+// setSnapshotRate applies a client's request for a different snapshot rate and tells it the result
+func (h *GameHub) setSnapshotRate(client *types.Client, rate int) {
+	// Views exist only for registered clients, whose Send channel is still open
+	if _, ok := h.views[client]; !ok {
+		return
+	}
+
+	client.SnapshotRate = max(rate, 0)
+	applied := h.snapshotRate(client)
+	log.Printf("Client %s snapshot rate set to %d/s", client.UUID, applied)
+
+	h.sendToClient(client, types.SnapshotRateMessage{
+		Type: string(types.SnapshotRateMsg),
+		Rate: applied,
+	})
+}
+
+// SetSnapshotRate queues a client's request for a different snapshot rate, 0 for the server's
+func (h *GameHub) SetSnapshotRate(c *types.Client, rate int) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "snapshotRate", Client: c, Data: rate}:
+	default:
+		log.Printf("Client action channel full, dropping snapshot rate from %s", c.UUID)
+	}
+}
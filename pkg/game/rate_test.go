@@ -0,0 +1,75 @@
+package game
+
+import (
+	"encoding/json"
+	"game-server-v1/pkg/types"
+	"testing"
+)
+
+func TestSnapshotDueSpreadsTheRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverRate int
+		clientRate int
+		want       int // snapshots over one second of ticks
+	}{
+		{"every tick by default", 0, 0, 30},
+		{"divides the tick rate", 0, 10, 10},
+		{"does not divide the tick rate", 0, 20, 20},
+		{"odd rate", 0, 7, 7},
+		{"client cannot exceed the tick rate", 0, 60, 30},
+		{"client cannot exceed the server", 10, 20, 10},
+		{"client may go below the server", 15, 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := types.GetDefaultConfig()
+			config.SnapshotRate = tt.serverRate
+			h := newTestHub(t, config)
+			client := &types.Client{SnapshotRate: tt.clientRate}
+			view := newClientView()
+
+			sent, gap, maxGap := 0, 0, 0
+			for tick := 0; tick < config.TickRate; tick++ {
+				gap++
+				if h.snapshotDue(client, view) {
+					sent++
+					maxGap = max(maxGap, gap)
+					gap = 0
+				}
+			}
+			if sent != tt.want {
+				t.Errorf("sent %d snapshots in a second, want %d", sent, tt.want)
+			}
+			// Credit spreads sends evenly rather than bunching them
+			if limit := (config.TickRate + tt.want - 1) / tt.want; maxGap > limit {
+				t.Errorf("%d ticks between snapshots, want at most %d", maxGap, limit)
+			}
+		})
+	}
+}
+
+func TestSetSnapshotRateReportsAppliedRate(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.SnapshotRate = 10
+	h := newTestHub(t, config)
+	client := addClient(h, "p1", types.EncodingJSON)
+	sentMessages(t, client)
+
+	for _, tt := range []struct{ requested, want int }{{5, 5}, {50, 10}, {0, 10}, {-3, 10}} {
+		h.setSnapshotRate(client, tt.requested)
+
+		msgs := sentMessages(t, client)[string(types.SnapshotRateMsg)]
+		if len(msgs) != 1 {
+			t.Fatalf("requested %d: got %d snapshotRate replies, want 1", tt.requested, len(msgs))
+		}
+		var reply types.SnapshotRateMessage
+		if err := json.Unmarshal(msgs[0], &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Rate != tt.want {
+			t.Errorf("requested %d: applied %d, want %d", tt.requested, reply.Rate, tt.want)
+		}
+	}
+}
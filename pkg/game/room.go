@@ -0,0 +1,258 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"game-server-v1/pkg/types"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Room errors
+var (
+	ErrRoomExists     = errors.New("room already exists")
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrTooManyRooms   = errors.New("too many rooms")
+	ErrInvalidRoomID  = errors.New("room id must be 1-32 letters, digits, '-' or '_'")
+	ErrPermanentRoom  = errors.New("the default room cannot be destroyed")
+	ErrManagerStopped = errors.New("room manager stopped")
+)
+
+// roomIDPattern keeps room ids safe to put in URLs and logs
+var roomIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Room is one independent game world with its own hub and config
+type Room struct {
+	ID        string
+	Hub       *GameHub
+	Config    *types.GameConfig
+	CreatedAt time.Time
+
+	// emptySince is when the room was last seen without players; zero while occupied
+	emptySince time.Time
+}
+
+// RoomInfo describes a room for listings
+type RoomInfo struct {
+	ID         string    `json:"id"`
+	Players    int       `json:"players"`
+	MaxPlayers int       `json:"maxPlayers"`
+	TickRate   int       `json:"tickRate"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// RoomManager hosts the server's rooms, each running its own GameHub. A default
+// room always exists; other rooms are destroyed once they stay empty for EmptyRoomTTL.
+type RoomManager struct {
+	config *types.GameConfig // base config every room's overrides apply to
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.RWMutex
+	rooms   map[string]*Room
+	stopped bool
+}
+
+// NewRoomManager creates a room manager with a running default room using config.
+// Rooms are stopped when ctx is cancelled or StopAll is called.
+func NewRoomManager(ctx context.Context, config *types.GameConfig) *RoomManager {
+	if config == nil {
+		config = types.GetDefaultConfig()
+	}
+
+	m := &RoomManager{
+		config: config,
+		done:   make(chan struct{}),
+		rooms:  make(map[string]*Room),
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	m.startRoom(types.DefaultRoomID, config)
+	go m.collectEmptyRooms()
+
+	return m
+}
+
+// Create starts a room with the overrides applied to the base config.
+// An empty id picks a fresh one.
+func (m *RoomManager) Create(id string, overrides *types.RoomOverrides) (*Room, error) {
+	if id == "" {
+		id = strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+	}
+	if !roomIDPattern.MatchString(id) {
+		return nil, ErrInvalidRoomID
+	}
+
+	config, err := m.config.WithOverrides(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("room %s: %w", id, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case m.stopped:
+		return nil, ErrManagerStopped
+	case m.rooms[id] != nil:
+		return nil, ErrRoomExists
+	case len(m.rooms) >= types.MaxRooms:
+		return nil, ErrTooManyRooms
+	}
+
+	room := m.startRoom(id, config)
+	log.Printf("Room %s created", id)
+	return room, nil
+}
+
+// Get returns the room with the given id; an empty id means the default room
+func (m *RoomManager) Get(id string) (*Room, bool) {
+	if id == "" {
+		id = types.DefaultRoomID
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	room, ok := m.rooms[id]
+	return room, ok
+}
+
+// List describes every room, ordered by id
+func (m *RoomManager) List() []RoomInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rooms := make([]RoomInfo, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, RoomInfo{
+			ID:         room.ID,
+			Players:    room.Hub.PlayerCount(),
+			MaxPlayers: room.Config.MaxPlayers,
+			TickRate:   room.Config.TickRate,
+			CreatedAt:  room.CreatedAt,
+		})
+	}
+	slices.SortFunc(rooms, func(a, b RoomInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return rooms
+}
+
+// Destroy stops a room, closing its clients' connections
+func (m *RoomManager) Destroy(id string) error {
+	return m.destroy(id, false)
+}
+
+// destroy removes and stops a room. With onlyIfEmpty a room that players joined
+// since it was found empty is kept, so collecting it never closes on a new client.
+func (m *RoomManager) destroy(id string, onlyIfEmpty bool) error {
+	if id == types.DefaultRoomID {
+		return ErrPermanentRoom
+	}
+
+	m.mu.Lock()
+	room, ok := m.rooms[id]
+	if ok && onlyIfEmpty && room.Hub.PlayerCount() > 0 {
+		room.emptySince = time.Time{}
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.rooms, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrRoomNotFound
+	}
+
+	room.Hub.StopWithReason("room closed")
+	log.Printf("Room %s destroyed", id)
+	return nil
+}
+
+// StopAll stops every room, including the default one, and waits for them to finish
+func (m *RoomManager) StopAll() {
+	m.mu.Lock()
+	m.stopped = true
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	m.mu.Unlock()
+
+	// Cancelling the manager's context stops every hub; wait for each to finish
+	m.cancel()
+	<-m.done
+
+	for _, room := range rooms {
+		<-room.Hub.Done()
+	}
+}
+
+// startRoom creates and starts a room's hub. Caller must hold the lock once the
+// manager is shared.
+func (m *RoomManager) startRoom(id string, config *types.GameConfig) *Room {
+	hub := NewGameHub(config)
+	hub.Start(m.ctx)
+
+	now := time.Now()
+	room := &Room{
+		ID:         id,
+		Hub:        hub,
+		Config:     config,
+		CreatedAt:  now,
+		emptySince: now,
+	}
+	m.rooms[id] = room
+	return room
+}
+
+// collectEmptyRooms destroys rooms that have had no players for EmptyRoomTTL
+func (m *RoomManager) collectEmptyRooms() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(types.RoomGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, id := range m.emptyRooms(now) {
+				if err := m.destroy(id, true); err != nil && !errors.Is(err, ErrRoomNotFound) {
+					log.Printf("Error destroying empty room %s: %v", id, err)
+				}
+			}
+		}
+	}
+}
+
+// emptyRooms updates when each room was last occupied and returns those empty for too long
+func (m *RoomManager) emptyRooms(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []string
+	for id, room := range m.rooms {
+		if room.Hub.PlayerCount() > 0 {
+			room.emptySince = time.Time{}
+			continue
+		}
+		if room.emptySince.IsZero() {
+			room.emptySince = now
+		}
+		if id != types.DefaultRoomID && now.Sub(room.emptySince) >= types.EmptyRoomTTL {
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
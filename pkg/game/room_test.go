@@ -0,0 +1,112 @@
+package game
+
+import (
+	"context"
+	"game-server-v1/pkg/types"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestRoomManager starts a room manager that is stopped when the test ends
+func newTestRoomManager(t *testing.T) *RoomManager {
+	t.Helper()
+
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	m := NewRoomManager(context.Background(), nil)
+	t.Cleanup(m.StopAll)
+	return m
+}
+
+// expireRoom makes a room look empty for longer than EmptyRoomTTL
+func expireRoom(m *RoomManager, room *Room) {
+	m.mu.Lock()
+	room.emptySince = time.Now().Add(-types.EmptyRoomTTL)
+	m.mu.Unlock()
+}
+
+func TestCollectingEmptyRooms(t *testing.T) {
+	m := newTestRoomManager(t)
+	idle, err := m.Create("idle", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined, err := m.Create("joined", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expireRoom(m, idle)
+	expireRoom(m, joined)
+
+	expired := m.emptyRooms(time.Now())
+	slices.Sort(expired)
+	if !slices.Equal(expired, []string{"idle", "joined"}) {
+		t.Fatalf("expired rooms %v, want idle and joined", expired)
+	}
+
+	// A client joins after the room was found empty but before it is destroyed
+	client := &types.Client{
+		UUID:     "p1",
+		Send:     make(chan []byte, 256),
+		Snapshot: make(chan []byte, 1),
+		Ping:     make(chan types.PingMessage, 1),
+		LastSeen: time.Now(),
+		Encoding: types.EncodingJSON,
+	}
+	if !joined.Hub.Register(client) {
+		t.Fatal("register refused")
+	}
+	for deadline := time.Now().Add(time.Second); joined.Hub.PlayerCount() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("client never joined")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, id := range expired {
+		if err := m.destroy(id, true); err != nil {
+			t.Fatalf("destroy %s: %v", id, err)
+		}
+	}
+	if _, ok := m.Get("idle"); ok {
+		t.Error("empty room was not destroyed")
+	}
+	if _, ok := m.Get("joined"); !ok {
+		t.Fatal("room destroyed after a client joined it")
+	}
+	select {
+	case <-joined.Hub.Done():
+		t.Error("hub of the joined room stopped")
+	default:
+	}
+	if got := m.emptyRooms(time.Now()); len(got) != 0 {
+		t.Errorf("occupied room still expired: %v", got)
+	}
+}
+
+func TestStopAllWaitsForRooms(t *testing.T) {
+	m := newTestRoomManager(t)
+	if _, err := m.Create("arena", nil); err != nil {
+		t.Fatal(err)
+	}
+	def, _ := m.Get("")
+	arena, _ := m.Get("arena")
+
+	m.StopAll()
+
+	for _, room := range []*Room{def, arena} {
+		select {
+		case <-room.Hub.Done():
+		default:
+			t.Errorf("room %s still running after StopAll returned", room.ID)
+		}
+	}
+	if _, err := m.Create("late", nil); err != ErrManagerStopped {
+		t.Errorf("create after StopAll: %v, want %v", err, ErrManagerStopped)
+	}
+}
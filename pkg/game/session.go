@@ -0,0 +1,206 @@
+package game
+
+import (
+	"crypto/rand"
+	"game-server-v1/pkg/types"
+	"log"
+	"sync"
+	"time"
+)
+
+// session ties a player to the connection controlling it, so the player can
+// outlive a dropped connection and be reclaimed with its resume token
+type session struct {
+	playerID       string
+	token          string
+	client         *types.Client // nil while disconnected
+	disconnectedAt time.Time
+	claimed        bool // a new connection presented the token and is registering
+}
+
+// sessionStore indexes sessions by player and resume token. Tokens are claimed
+// from connection goroutines, so unlike other hub state it has its own lock.
+type sessionStore struct {
+	byPlayer map[string]*session
+	byToken  map[string]*session
+	mu       sync.Mutex
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		byPlayer: make(map[string]*session),
+		byToken:  make(map[string]*session),
+	}
+}
+
+// open starts a session for a newly created player and returns its resume token
+func (s *sessionStore) open(playerID string, client *types.Client) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := &session{playerID: playerID, client: client}
+	s.byPlayer[playerID] = sess
+	return s.issueToken(sess)
+}
+
+// claim consumes a resume token and returns the player it belongs to.
+// The session is held until the claiming connection registers.
+func (s *sessionStore) claim(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byToken[token]
+	if !ok {
+		return "", false
+	}
+	delete(s.byToken, token)
+	sess.token = ""
+	sess.claimed = true
+	return sess.playerID, true
+}
+
+// release undoes a claim whose connection failed before registering, so the
+// token can be presented again and the session expires as usual
+func (s *sessionStore) release(playerID, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byPlayer[playerID]
+	if !ok || !sess.claimed {
+		return
+	}
+	sess.claimed = false
+	sess.token = token
+	s.byToken[token] = sess
+}
+
+// attach hands a claimed session to the registering client, returning the
+// connection it replaces (if still open) and a fresh resume token
+func (s *sessionStore) attach(client *types.Client) (previous *types.Client, token string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byPlayer[client.UUID]
+	if !ok || !sess.claimed {
+		return nil, "", false
+	}
+	previous = sess.client
+	sess.client = client
+	sess.claimed = false
+	return previous, s.issueToken(sess), true
+}
+
+// detach marks the client's session as disconnected. It reports false when the
+// session has already been taken over by another connection.
+func (s *sessionStore) detach(client *types.Client, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byPlayer[client.Player.ID]
+	if !ok || sess.client != client {
+		return false
+	}
+	sess.client = nil
+	sess.disconnectedAt = now
+	return true
+}
+
+// expire removes sessions that have been disconnected longer than grace and
+// returns their player IDs
+func (s *sessionStore) expire(now time.Time, grace time.Duration) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []string
+	for id, sess := range s.byPlayer {
+		if sess.client != nil || sess.claimed || now.Sub(sess.disconnectedAt) < grace {
+			continue
+		}
+		s.remove(id)
+		expired = append(expired, id)
+	}
+	return expired
+}
+
+// close discards the session for a player that has left the world
+func (s *sessionStore) close(playerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(playerID)
+}
+
+// remove deletes a session and its token. Caller must hold the store lock.
+func (s *sessionStore) remove(playerID string) {
+	if sess, ok := s.byPlayer[playerID]; ok {
+		delete(s.byToken, sess.token)
+		delete(s.byPlayer, playerID)
+	}
+}
+
+// issueToken replaces the session's resume token. Caller must hold the store lock.
+func (s *sessionStore) issueToken(sess *session) string {
+	delete(s.byToken, sess.token)
+	sess.token = rand.Text()
+	s.byToken[sess.token] = sess
+	return sess.token
+}
+
+// resumeSession reattaches a registering client to the player it reclaimed,
+// replacing any connection still bound to that player. It reports false when
+// the client did not present a valid resume token.
+func (h *GameHub) resumeSession(client *types.Client) bool {
+	previous, token, ok := h.sessions.attach(client)
+	if !ok {
+		return false
+	}
+
+	h.state.mu.RLock()
+	player, ok := h.state.Players[client.UUID]
+	h.state.mu.RUnlock()
+	if !ok {
+		h.sessions.close(client.UUID)
+		return false
+	}
+
+	// Detach the replaced connection so its eventual unregister leaves the player alone
+	if previous != nil && previous != client {
+		h.dropClient(previous)
+		previous.Player = nil
+	}
+
+	client.Player = player
+	client.RTT = h.lastRTT(player.ID)
+	if buf, ok := h.inputs[player.ID]; ok {
+		buf.reset()
+	}
+
+	h.sendPlayerID(client, token, true)
+
+	// Resync from scratch; the client's old baselines died with its connection
+	h.sendGameStateToClient(client, h.snapshotState())
+	h.resumeEventLog(client)
+
+	log.Printf("Player %s resumed session", player.ID)
+	return true
+}
+
+// expireSessions removes players whose connection did not come back within the grace period
+func (h *GameHub) expireSessions(now time.Time) {
+	for _, id := range h.sessions.expire(now, h.config.ReconnectGrace) {
+		h.removePlayer(id)
+		log.Printf("Player %s did not reconnect, removed", id)
+	}
+}
+
+// ResumeSession consumes a resume token and returns the player ID the new
+// connection should register with to reclaim its player. If the connection
+// fails before registering, it must hand the token back with ReleaseSession.
+func (h *GameHub) ResumeSession(token string) (string, bool) {
+	return h.sessions.claim(token)
+}
+
+// ReleaseSession returns a token claimed by ResumeSession for a connection that
+// never registered
+func (h *GameHub) ReleaseSession(playerID, token string) {
+	h.sessions.release(playerID, token)
+}
@@ -0,0 +1,293 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"time"
+)
+
+// snapshot is the replicated state of the world at one tick
+type snapshot struct {
+	tick        uint64
+	time        time.Time
+	players     map[string]types.PlayerSnapshot
+	projectiles map[string]types.ProjectileSnapshot
+
+	// owned lists projectile ids by owner; only set on the world snapshot, for interest filtering
+	owned map[string][]string
+}
+
+// newSnapshot extracts the replicated fields from a GameState copy
+func newSnapshot(gs *GameState) *snapshot {
+	snap := &snapshot{
+		tick:        gs.Tick,
+		time:        gs.LastUpdate,
+		players:     make(map[string]types.PlayerSnapshot, len(gs.Players)),
+		projectiles: make(map[string]types.ProjectileSnapshot, len(gs.Projectiles)),
+		owned:       make(map[string][]string, len(gs.Players)),
+	}
+
+	for id, p := range gs.Players {
+		snap.players[id] = types.PlayerSnapshot{
+			ID:                 p.ID,
+			NetID:              p.NetID,
+			PosX:               p.PosX,
+			PosY:               p.PosY,
+			MoveX:              p.MoveX,
+			MoveY:              p.MoveY,
+			FacingLeft:         p.FacingLeft,
+			Health:             p.Health,
+			IsAlive:            p.IsAlive,
+			Invulnerable:       p.Invulnerable,
+			Weapon:             p.Weapon,
+			Ammo:               p.Ammo,
+			Reloading:          p.Reloading,
+			LastProcessedInput: p.LastProcessedInput,
+		}
+	}
+
+	for id, proj := range gs.Projectiles {
+		var ownerNetID uint32
+		if owner, ok := gs.Players[proj.OwnerID]; ok {
+			ownerNetID = owner.NetID
+		}
+
+		snap.projectiles[id] = types.ProjectileSnapshot{
+			ID:         proj.ID,
+			NetID:      proj.NetID,
+			OwnerID:    proj.OwnerID,
+			OwnerNetID: ownerNetID,
+			PosX:       proj.PosX,
+			PosY:       proj.PosY,
+			VelX:       proj.VelX,
+			VelY:       proj.VelY,
+			Radius:     proj.Radius,
+		}
+		snap.owned[proj.OwnerID] = append(snap.owned[proj.OwnerID], id)
+	}
+
+	return snap
+}
+
+// clientView tracks the snapshots sent to one client and which of them it acknowledged
+type clientView struct {
+	sent    map[uint64]*snapshot // tick → snapshot sent at that tick
+	ackTick uint64
+	acked   bool
+
+	// entities currently inside the client's area of interest
+	interest *interestSet
+
+	// the last time sync ping, superseded by the next one if never answered
+	pingID       uint32
+	pingQueuedAt time.Time
+	pingPending  bool
+
+	// when every snapshot started replacing an unsent one, zero while the client keeps up
+	laggingSince time.Time
+	kicked       bool // disconnected for lagging, awaiting unregister
+
+	// Bandwidth budget: bytes the client may still be sent, the estimated cost of
+	// one changed entity, and the priority skipped entities have accumulated
+	budget     float64
+	entityCost float64
+	priority   map[entityKey]float64
+
+	// credit earned toward the next send at the client's snapshot rate, in 1/TickRate of a snapshot
+	snapshotCredit int
+}
+
+func newClientView() *clientView {
+	return &clientView{
+		sent:     make(map[uint64]*snapshot),
+		interest: newInterestSet(),
+		priority: make(map[entityKey]float64),
+	}
+}
+
+// remember records a snapshot sent to the client and forgets ones too old to be a baseline
+func (v *clientView) remember(snap *snapshot, maxAge int) {
+	v.sent[snap.tick] = snap
+	for tick := range v.sent {
+		if tick+uint64(maxAge) < snap.tick {
+			delete(v.sent, tick)
+		}
+	}
+}
+
+// acknowledge marks a sent snapshot as applied by the client
+func (v *clientView) acknowledge(tick uint64) {
+	if _, ok := v.sent[tick]; !ok || (v.acked && tick <= v.ackTick) {
+		return
+	}
+	v.ackTick = tick
+	v.acked = true
+
+	// Anything older than the newest ack will never be used as a baseline
+	for t := range v.sent {
+		if t < tick {
+			delete(v.sent, t)
+		}
+	}
+}
+
+// reset forgets all baselines so the next snapshot is sent in full
+func (v *clientView) reset() {
+	clear(v.sent)
+	v.ackTick = 0
+	v.acked = false
+}
+
+// baseline returns the acknowledged snapshot to delta against,
+// or nil when the client needs a full snapshot
+func (v *clientView) baseline(tick uint64, maxAge int) *snapshot {
+	if !v.acked || v.ackTick+uint64(maxAge) < tick {
+		return nil
+	}
+	return v.sent[v.ackTick]
+}
+
+// diffPlayer returns the changed fields, every field when old is nil, or nil when nothing changed
+func diffPlayer(old, cur *types.PlayerSnapshot) *types.PlayerDelta {
+	d := &types.PlayerDelta{}
+	changed := false
+
+	if old == nil {
+		d.NetID = &cur.NetID
+	}
+	if old == nil || old.PosX != cur.PosX {
+		d.PosX, changed = &cur.PosX, true
+	}
+	if old == nil || old.PosY != cur.PosY {
+		d.PosY, changed = &cur.PosY, true
+	}
+	if old == nil || old.MoveX != cur.MoveX {
+		d.MoveX, changed = &cur.MoveX, true
+	}
+	if old == nil || old.MoveY != cur.MoveY {
+		d.MoveY, changed = &cur.MoveY, true
+	}
+	if old == nil || old.FacingLeft != cur.FacingLeft {
+		d.FacingLeft, changed = &cur.FacingLeft, true
+	}
+	if old == nil || old.Health != cur.Health {
+		d.Health, changed = &cur.Health, true
+	}
+	if old == nil || old.IsAlive != cur.IsAlive {
+		d.IsAlive, changed = &cur.IsAlive, true
+	}
+	if old == nil || old.Invulnerable != cur.Invulnerable {
+		d.Invulnerable, changed = &cur.Invulnerable, true
+	}
+	if old == nil || old.Weapon != cur.Weapon {
+		d.Weapon, changed = &cur.Weapon, true
+	}
+	if old == nil || old.Ammo != cur.Ammo {
+		d.Ammo, changed = &cur.Ammo, true
+	}
+	if old == nil || old.Reloading != cur.Reloading {
+		d.Reloading, changed = &cur.Reloading, true
+	}
+	if old == nil || old.LastProcessedInput != cur.LastProcessedInput {
+		d.LastProcessedInput, changed = &cur.LastProcessedInput, true
+	}
+
+	if !changed {
+		return nil
+	}
+	return d
+}
+
+// diffProjectile returns the changed fields, every field when old is nil, or nil when nothing changed
+func diffProjectile(old, cur *types.ProjectileSnapshot) *types.ProjectileDelta {
+	d := &types.ProjectileDelta{}
+	changed := false
+
+	if old == nil {
+		d.NetID = &cur.NetID
+	}
+	if old == nil || old.OwnerID != cur.OwnerID {
+		d.OwnerID, changed = &cur.OwnerID, true
+	}
+	if old == nil || old.PosX != cur.PosX {
+		d.PosX, changed = &cur.PosX, true
+	}
+	if old == nil || old.PosY != cur.PosY {
+		d.PosY, changed = &cur.PosY, true
+	}
+	if old == nil || old.VelX != cur.VelX {
+		d.VelX, changed = &cur.VelX, true
+	}
+	if old == nil || old.VelY != cur.VelY {
+		d.VelY, changed = &cur.VelY, true
+	}
+	if old == nil || old.Radius != cur.Radius {
+		d.Radius, changed = &cur.Radius, true
+	}
+
+	if !changed {
+		return nil
+	}
+	return d
+}
+
+// encoderKey identifies one cached encoding of the shared snapshot
+type encoderKey struct {
+	encoding string
+	full     bool
+	baseTick uint64
+}
+
+// snapshotEncoder encodes one tick's snapshot for many clients, sharing the work.
+// Clients with the same encoding that acknowledged the same baseline of the shared
+// snapshot get the same message; filtered snapshots are assembled from shared fragments.
+type snapshotEncoder struct {
+	hub       *GameHub
+	snap      *snapshot
+	cache     map[encoderKey][]byte
+	fragments map[string]*fragmentCache
+}
+
+func (h *GameHub) newSnapshotEncoder(snap *snapshot) *snapshotEncoder {
+	return &snapshotEncoder{
+		hub:       h,
+		snap:      snap,
+		cache:     make(map[encoderKey][]byte),
+		fragments: make(map[string]*fragmentCache),
+	}
+}
+
+// encode returns snap as a delta against the client's acknowledged baseline, or in full
+func (e *snapshotEncoder) encode(view *clientView, snap *snapshot, encoding string) ([]byte, error) {
+	base := view.baseline(snap.tick, e.hub.config.SnapshotHistory)
+
+	key := encoderKey{encoding: encoding, full: base == nil}
+	if base != nil {
+		key.baseTick = base.tick
+	}
+
+	shared := snap == e.snap
+	if shared {
+		if data, ok := e.cache[key]; ok {
+			return data, nil
+		}
+	}
+
+	fragments, ok := e.fragments[encoding]
+	if !ok {
+		fragments = e.hub.newFragmentCache(encoding)
+		e.fragments[encoding] = fragments
+	}
+	data, err := fragments.assemble(base, snap)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		e.cache[key] = data
+	}
+	return data, nil
+}
+
+// encodeSnapshot encodes snap in full when base is nil, otherwise as a delta against base
+func (h *GameHub) encodeSnapshot(base, snap *snapshot, encoding string) ([]byte, error) {
+	return h.newFragmentCache(encoding).assemble(base, snap)
+}
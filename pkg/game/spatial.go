@@ -0,0 +1,165 @@
+package game
+
+import "math"
+
+// EntityKind identifies what a spatial grid entry refers to
+type EntityKind uint8
+
+const (
+	EntityPlayer EntityKind = iota
+	EntityProjectile
+	EntityBox
+	EntityCircle
+)
+
+// SpatialEntry is an entity's bounding circle stored in a SpatialGrid.
+// ID is set for players and projectiles; Index points into World.Boxes or World.Circles for obstacles.
+type SpatialEntry struct {
+	ID     string
+	Kind   EntityKind
+	Index  int
+	X, Y   float64
+	Radius float64
+}
+
+type cellKey struct {
+	X, Y int32
+}
+
+// SpatialGrid is a uniform grid that buckets entities by position for broad-phase queries.
+// Queries return candidates whose bounding circle overlaps the query area; callers do the exact test.
+// It is not safe for concurrent use.
+type SpatialGrid struct {
+	cellSize float64
+	entries  []SpatialEntry
+	cells    map[cellKey][]int32
+
+	// occupied cell range, used to bound queries larger than the populated area
+	minCell, maxCell cellKey
+
+	// marks deduplicate entries spanning several cells within one query
+	marks []uint32
+	query uint32
+}
+
+// NewSpatialGrid creates an empty grid with square cells of the given size
+func NewSpatialGrid(cellSize float64) *SpatialGrid {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+	return &SpatialGrid{
+		cellSize: cellSize,
+		cells:    make(map[cellKey][]int32),
+	}
+}
+
+// Clear removes all entries while keeping allocated cells for reuse
+func (g *SpatialGrid) Clear() {
+	g.entries = g.entries[:0]
+	g.marks = g.marks[:0]
+	for key, bucket := range g.cells {
+		g.cells[key] = bucket[:0]
+	}
+}
+
+// Len returns the number of entries in the grid
+func (g *SpatialGrid) Len() int {
+	return len(g.entries)
+}
+
+// Insert adds an entry to every cell its bounding circle covers
+func (g *SpatialGrid) Insert(entry SpatialEntry) {
+	index := int32(len(g.entries))
+	g.entries = append(g.entries, entry)
+	g.marks = append(g.marks, 0)
+
+	minX, minY := g.cell(entry.X-entry.Radius, entry.Y-entry.Radius)
+	maxX, maxY := g.cell(entry.X+entry.Radius, entry.Y+entry.Radius)
+
+	if index == 0 {
+		g.minCell = cellKey{minX, minY}
+		g.maxCell = cellKey{maxX, maxY}
+	} else {
+		g.minCell = cellKey{min(g.minCell.X, minX), min(g.minCell.Y, minY)}
+		g.maxCell = cellKey{max(g.maxCell.X, maxX), max(g.maxCell.Y, maxY)}
+	}
+
+	// int64 counters so a range ending at a saturated cell doesn't overflow and loop forever
+	for cx := int64(minX); cx <= int64(maxX); cx++ {
+		for cy := int64(minY); cy <= int64(maxY); cy++ {
+			key := cellKey{int32(cx), int32(cy)}
+			g.cells[key] = append(g.cells[key], index)
+		}
+	}
+}
+
+// QueryRadius calls fn for each entry whose bounding circle overlaps the given circle.
+// Iteration stops early when fn returns false.
+func (g *SpatialGrid) QueryRadius(x, y, radius float64, fn func(*SpatialEntry) bool) {
+	g.visit(x-radius, y-radius, x+radius, y+radius, func(e *SpatialEntry) bool {
+		if math.Hypot(e.X-x, e.Y-y) > e.Radius+radius {
+			return true
+		}
+		return fn(e)
+	})
+}
+
+// QueryRect calls fn for each entry whose bounding circle overlaps the given rectangle.
+// Iteration stops early when fn returns false.
+func (g *SpatialGrid) QueryRect(minX, minY, maxX, maxY float64, fn func(*SpatialEntry) bool) {
+	g.visit(minX, minY, maxX, maxY, func(e *SpatialEntry) bool {
+		cx := math.Max(minX, math.Min(e.X, maxX))
+		cy := math.Max(minY, math.Min(e.Y, maxY))
+		if math.Hypot(e.X-cx, e.Y-cy) > e.Radius {
+			return true
+		}
+		return fn(e)
+	})
+}
+
+// visit calls fn once for every entry stored in cells overlapping the rectangle
+func (g *SpatialGrid) visit(minX, minY, maxX, maxY float64, fn func(*SpatialEntry) bool) {
+	if len(g.entries) == 0 {
+		return
+	}
+
+	g.query++
+	if g.query == 0 {
+		// Stamp counter wrapped; reset marks so old stamps can't collide
+		clear(g.marks)
+		g.query = 1
+	}
+
+	cminX, cminY := g.cell(minX, minY)
+	cmaxX, cmaxY := g.cell(maxX, maxY)
+	cminX, cminY = max(cminX, g.minCell.X), max(cminY, g.minCell.Y)
+	cmaxX, cmaxY = min(cmaxX, g.maxCell.X), min(cmaxY, g.maxCell.Y)
+	for cx := int64(cminX); cx <= int64(cmaxX); cx++ {
+		for cy := int64(cminY); cy <= int64(cmaxY); cy++ {
+			for _, index := range g.cells[cellKey{int32(cx), int32(cy)}] {
+				if g.marks[index] == g.query {
+					continue
+				}
+				g.marks[index] = g.query
+				if !fn(&g.entries[index]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// cell returns the grid coordinates containing a point, saturating far outside the int32 range
+func (g *SpatialGrid) cell(x, y float64) (int32, int32) {
+	return clampCell(math.Floor(x / g.cellSize)), clampCell(math.Floor(y / g.cellSize))
+}
+
+func clampCell(v float64) int32 {
+	if v != v || v < math.MinInt32 {
+		return math.MinInt32
+	}
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(v)
+}
@@ -0,0 +1,204 @@
+package game
+
+import (
+	"fmt"
+	"game-server-v1/pkg/types"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomEntries scatters players and projectiles over the default world
+func randomEntries(players, projectiles int, rng *rand.Rand) []SpatialEntry {
+	entries := make([]SpatialEntry, 0, players+projectiles)
+	for i := 0; i < players+projectiles; i++ {
+		entry := SpatialEntry{
+			ID:     fmt.Sprintf("entity-%d", i),
+			Kind:   EntityPlayer,
+			X:      rng.Float64() * types.DefaultMaxX,
+			Y:      rng.Float64() * types.DefaultMaxY,
+			Radius: types.DefaultPlayerRadius,
+		}
+		if i >= players {
+			entry.Kind = EntityProjectile
+			entry.Radius = 0.25
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// queryRadius collects the IDs a radius query reports, failing on duplicates
+func queryRadius(t *testing.T, grid *SpatialGrid, x, y, radius float64) []string {
+	t.Helper()
+	var ids []string
+	grid.QueryRadius(x, y, radius, func(e *SpatialEntry) bool {
+		if slices.Contains(ids, e.ID) {
+			t.Errorf("entry %s reported twice", e.ID)
+		}
+		ids = append(ids, e.ID)
+		return true
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSpatialGridQueryRadiusMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	entries := randomEntries(50, 500, rng)
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	for _, e := range entries {
+		grid.Insert(e)
+	}
+	if grid.Len() != len(entries) {
+		t.Fatalf("Len() = %d, want %d", grid.Len(), len(entries))
+	}
+
+	for i := 0; i < 200; i++ {
+		x, y := rng.Float64()*types.DefaultMaxX, rng.Float64()*types.DefaultMaxY
+		radius := rng.Float64() * 10
+
+		var want []string
+		for _, e := range entries {
+			if math.Hypot(e.X-x, e.Y-y) <= e.Radius+radius {
+				want = append(want, e.ID)
+			}
+		}
+		slices.Sort(want)
+
+		if got := queryRadius(t, grid, x, y, radius); !slices.Equal(got, want) {
+			t.Fatalf("QueryRadius(%g, %g, %g) = %v, want %v", x, y, radius, got, want)
+		}
+	}
+}
+
+func TestSpatialGridQueryRectMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	entries := randomEntries(50, 500, rng)
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	for _, e := range entries {
+		grid.Insert(e)
+	}
+
+	for i := 0; i < 200; i++ {
+		minX, minY := rng.Float64()*types.DefaultMaxX, rng.Float64()*types.DefaultMaxY
+		maxX, maxY := minX+rng.Float64()*10, minY+rng.Float64()*10
+
+		var want []string
+		for _, e := range entries {
+			cx := math.Max(minX, math.Min(e.X, maxX))
+			cy := math.Max(minY, math.Min(e.Y, maxY))
+			if math.Hypot(e.X-cx, e.Y-cy) <= e.Radius {
+				want = append(want, e.ID)
+			}
+		}
+		slices.Sort(want)
+
+		var got []string
+		grid.QueryRect(minX, minY, maxX, maxY, func(e *SpatialEntry) bool {
+			got = append(got, e.ID)
+			return true
+		})
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Fatalf("QueryRect(%g, %g, %g, %g) = %v, want %v", minX, minY, maxX, maxY, got, want)
+		}
+	}
+}
+
+func TestSpatialGridLargeEntryReportedOnce(t *testing.T) {
+	grid := NewSpatialGrid(1)
+	grid.Insert(SpatialEntry{ID: "wall", Kind: EntityBox, X: 0, Y: 0, Radius: 10})
+
+	// The entry covers hundreds of cells, all of them inside the query
+	if got := queryRadius(t, grid, 0, 0, 20); !slices.Equal(got, []string{"wall"}) {
+		t.Fatalf("got %v, want [wall]", got)
+	}
+	// A second query must find it again despite the marks left by the first
+	if got := queryRadius(t, grid, 5, 5, 1); !slices.Equal(got, []string{"wall"}) {
+		t.Fatalf("second query got %v, want [wall]", got)
+	}
+}
+
+func TestSpatialGridQueryStopsEarly(t *testing.T) {
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	for _, e := range randomEntries(10, 10, rand.New(rand.NewPCG(5, 6))) {
+		grid.Insert(e)
+	}
+
+	calls := 0
+	grid.QueryRadius(0, 0, 1000, func(*SpatialEntry) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Fatalf("callback ran %d times after asking to stop at 3", calls)
+	}
+}
+
+func TestSpatialGridClear(t *testing.T) {
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	grid.Insert(SpatialEntry{ID: "a", X: 1, Y: 1, Radius: 1})
+	grid.Clear()
+
+	if grid.Len() != 0 {
+		t.Fatalf("Len() = %d after Clear, want 0", grid.Len())
+	}
+	if got := queryRadius(t, grid, 1, 1, 5); len(got) != 0 {
+		t.Fatalf("query after Clear found %v", got)
+	}
+
+	grid.Insert(SpatialEntry{ID: "b", X: 20, Y: 20, Radius: 1})
+	if got := queryRadius(t, grid, 20, 20, 1); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("query after reuse got %v, want [b]", got)
+	}
+}
+
+func TestSpatialGridExtremeCoordinates(t *testing.T) {
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	grid.Insert(SpatialEntry{ID: "near", X: 0, Y: 0, Radius: 1})
+	grid.Insert(SpatialEntry{ID: "far", X: 1e300, Y: -1e300, Radius: 1})
+	grid.Insert(SpatialEntry{ID: "nan", X: math.NaN(), Y: 0, Radius: 1})
+
+	// Saturated cells must neither panic nor make a small query scan the whole range
+	if got := queryRadius(t, grid, 0, 0, 2); !slices.Equal(got, []string{"near"}) {
+		t.Fatalf("got %v, want [near]", got)
+	}
+}
+
+// BenchmarkSpatialGridRebuild measures clearing and re-indexing the grid, as every tick does
+func BenchmarkSpatialGridRebuild(b *testing.B) {
+	entries := randomEntries(100, 1000, rand.New(rand.NewPCG(1, 2)))
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+
+	for b.Loop() {
+		grid.Clear()
+		for _, e := range entries {
+			grid.Insert(e)
+		}
+	}
+}
+
+// BenchmarkSpatialGridQueryRadius measures radius queries the size of a hit test and of
+// an interest area
+func BenchmarkSpatialGridQueryRadius(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	grid := NewSpatialGrid(types.DefaultSpatialCellSize)
+	for _, e := range randomEntries(100, 1000, rng) {
+		grid.Insert(e)
+	}
+
+	for _, radius := range []float64{1, types.DefaultInterestRadius} {
+		b.Run(fmt.Sprintf("radius=%g", radius), func(b *testing.B) {
+			found := 0
+			for b.Loop() {
+				grid.QueryRadius(rng.Float64()*types.DefaultMaxX, rng.Float64()*types.DefaultMaxY, radius, func(*SpatialEntry) bool {
+					found++
+					return true
+				})
+			}
+			b.ReportMetric(float64(found)/float64(b.N), "entries/op")
+		})
+	}
+}
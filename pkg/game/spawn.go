@@ -0,0 +1,105 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// chooseSpawnPoint picks the spawn point farthest from any living enemy.
+// Caller must hold the GameState lock.
+func (h *GameHub) chooseSpawnPoint(playerID string) types.SpawnPoint {
+	points := h.world.SpawnPoints
+	if len(points) == 0 {
+		return types.SpawnPoint{}
+	}
+
+	// Start at a random point so ties don't always resolve to the same spawn
+	offset := rand.Intn(len(points))
+	best := points[offset]
+	bestDist := -1.0
+
+	for i := range points {
+		point := points[(offset+i)%len(points)]
+
+		nearest := math.Inf(1)
+		for _, other := range h.state.Players {
+			if other.ID == playerID || !other.IsAlive {
+				continue
+			}
+			nearest = math.Min(nearest, math.Hypot(other.PosX-point.X, other.PosY-point.Y))
+		}
+
+		if nearest > bestDist {
+			best = point
+			bestDist = nearest
+		}
+	}
+
+	return best
+}
+
+// spawnPlayer places a player at a spawn point with full health and spawn protection.
+// Caller must hold the GameState lock.
+func (h *GameHub) spawnPlayer(player *types.Player, now time.Time) {
+	point := h.chooseSpawnPoint(player.ID)
+	player.PosX, player.PosY = h.world.ResolveCircle(point.X, point.Y, h.config.PlayerRadius)
+	player.MoveX = 0
+	player.MoveY = 0
+	player.Health = player.MaxHealth
+	player.IsAlive = true
+	player.DiedAt = time.Time{}
+	player.SpawnedAt = now
+	player.ProtectedUntil = now.Add(h.config.SpawnProtection)
+	player.Invulnerable = h.config.SpawnProtection > 0
+	player.LastUpdate = now
+
+	// Fresh spawns start with a loaded weapon
+	if !h.equipWeapon(player, player.Weapon) {
+		h.equipWeapon(player, h.config.DefaultWeapon)
+	}
+}
+
+// updateRespawns revives players whose respawn delay has elapsed and expires spawn protection.
+// Caller must hold the GameState lock.
+func (h *GameHub) updateRespawns(now time.Time) []*types.Player {
+	var respawned []*types.Player
+
+	for id, player := range h.state.Players {
+		if player.IsAlive {
+			if player.Invulnerable && !now.Before(player.ProtectedUntil) {
+				player.Invulnerable = false
+			}
+			continue
+		}
+
+		if now.Sub(player.DiedAt) < h.config.RespawnDelay {
+			continue
+		}
+
+		h.spawnPlayer(player, now)
+
+		// Inputs queued while dead must not move the fresh spawn
+		if buf, ok := h.inputs[id]; ok {
+			buf.reset()
+		}
+
+		respawned = append(respawned, player)
+	}
+
+	return respawned
+}
+
+// broadcastPlayerRespawned announces a respawn to all clients
+func (h *GameHub) broadcastPlayerRespawned(player *types.Player) {
+	h.publishEvent(types.PlayerRespawnedMessage{
+		Type:         string(types.PlayerRespawnMsg),
+		PlayerID:     player.ID,
+		NetID:        player.NetID,
+		PosX:         player.PosX,
+		PosY:         player.PosY,
+		Health:       player.Health,
+		ProtectedFor: h.config.SpawnProtection.Seconds(),
+	}, nil)
+}
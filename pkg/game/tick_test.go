@@ -0,0 +1,119 @@
+package game
+
+import (
+	"fmt"
+	"game-server-v1/pkg/types"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// benchHub creates a hub that is not running, so a benchmark can drive its run loop
+// methods directly, with players connected over the given encoding
+func benchHub(b *testing.B, players int, encoding string) (*GameHub, []*types.Client) {
+	b.Helper()
+
+	config := types.GetDefaultConfig()
+	// A weapon without cooldown or ammo so any number of projectiles can be in flight
+	config.Weapons["bench"] = types.WeaponConfig{
+		ProjectileSpeed: 5,
+		Radius:          0.25,
+		Lifetime:        5 * time.Second,
+	}
+	config.DefaultWeapon = "bench"
+
+	h := newTestHub(b, config)
+	clients := make([]*types.Client, players)
+	for i := range clients {
+		clients[i] = addClient(h, fmt.Sprintf("player-%d", i), encoding)
+	}
+	drainClients(clients)
+	return h, clients
+}
+
+// drainClients empties every client's queues like their WritePumps would
+func drainClients(clients []*types.Client) {
+	for _, c := range clients {
+		for len(c.Send) > 0 {
+			<-c.Send
+		}
+		for len(c.Snapshot) > 0 {
+			<-c.Snapshot
+		}
+		for len(c.Ping) > 0 {
+			<-c.Ping
+		}
+	}
+}
+
+// driveTick queues one random-walk input per player and tops projectiles up to target
+func driveTick(h *GameHub, clients []*types.Client, seq int64, target int, rng *rand.Rand) {
+	for _, c := range clients {
+		h.handlePlayerInput(&types.PlayerInputMessage{
+			Type:       string(types.PlayerInputMsg),
+			PlayerID:   c.UUID,
+			MoveX:      rng.Float64()*2 - 1,
+			MoveY:      rng.Float64()*2 - 1,
+			SequenceID: seq,
+		})
+	}
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	weapon := h.config.Weapons["bench"]
+	now := time.Now()
+	for len(h.state.Projectiles) < target {
+		owner := h.state.Players[clients[rng.IntN(len(clients))].UUID]
+		angle := rng.Float64() * 2 * math.Pi
+		proj := NewProjectile(owner, weapon, math.Cos(angle), math.Sin(angle), now)
+		proj.NetID = h.allocNetID()
+		h.state.Projectiles[proj.ID] = proj
+	}
+}
+
+var tickLoads = []struct{ players, projectiles int }{
+	{10, 100},
+	{100, 1000},
+}
+
+// BenchmarkGameTick measures a whole tick: simulation plus snapshots for every client
+func BenchmarkGameTick(b *testing.B) {
+	for _, load := range tickLoads {
+		for _, encoding := range []string{types.EncodingJSON, types.EncodingBinary} {
+			name := fmt.Sprintf("players=%d/projectiles=%d/%s", load.players, load.projectiles, encoding)
+			b.Run(name, func(b *testing.B) {
+				h, clients := benchHub(b, load.players, encoding)
+				rng := rand.New(rand.NewPCG(1, 2))
+				seq := int64(0)
+
+				for b.Loop() {
+					seq++
+					driveTick(h, clients, seq, load.projectiles, rng)
+					h.gameTick()
+					drainClients(clients)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkSimulation measures the fixed-step simulation alone, without snapshots
+func BenchmarkSimulation(b *testing.B) {
+	for _, load := range tickLoads {
+		name := fmt.Sprintf("players=%d/projectiles=%d", load.players, load.projectiles)
+		b.Run(name, func(b *testing.B) {
+			h, clients := benchHub(b, load.players, types.EncodingJSON)
+			rng := rand.New(rand.NewPCG(1, 2))
+			seq := int64(0)
+
+			for b.Loop() {
+				seq++
+				driveTick(h, clients, seq, load.projectiles, rng)
+				h.updateGameState()
+				drainClients(clients)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"log"
+	"time"
+)
+
+// rttWeight is how far each sample moves the smoothed RTT, as in RFC 6298
+const rttWeight = 0.125
+
+// pong is a ping answer stamped with the time its connection read it
+type pong struct {
+	id         uint32
+	receivedAt time.Time
+}
+
+// sendPings pings each client that negotiated time sync once per TimeSyncInterval.
+// A ping left unanswered is simply superseded by the next. The WritePump stamps each
+// ping as it writes it, so time spent queued behind other messages is not counted
+// as round-trip time.
+func (h *GameHub) sendPings(now time.Time) {
+	if h.config.TimeSyncInterval <= 0 {
+		return
+	}
+
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+
+	for client := range h.clients {
+		if !client.HasFeature(types.FeatureTimeSync) {
+			continue
+		}
+		view, ok := h.views[client]
+		if !ok || now.Sub(view.pingQueuedAt) < h.config.TimeSyncInterval {
+			continue
+		}
+
+		ping := types.PingMessage{
+			Type: string(types.PingMsg),
+			ID:   view.pingID + 1,
+			RTT:  client.RTT.Seconds(),
+		}
+		select {
+		case client.Ping <- ping:
+		default:
+			// The last ping is still waiting to be written; try again next tick
+			continue
+		}
+		view.pingID = ping.ID
+		view.pingQueuedAt = now
+		view.pingPending = true
+	}
+}
+
+// handlePong folds the answer to the outstanding ping into the client's RTT
+func (h *GameHub) handlePong(client *types.Client, p pong) {
+	view, ok := h.views[client]
+	if !ok || !view.pingPending || p.id != view.pingID {
+		return
+	}
+	sentAt, ok := client.PingWrittenAt(p.id)
+	if !ok {
+		return
+	}
+	sample := p.receivedAt.Sub(sentAt)
+	view.pingPending = false
+	if sample < 0 {
+		return
+	}
+
+	if client.RTT == 0 {
+		client.RTT = sample
+	} else {
+		client.RTT += time.Duration(rttWeight * float64(sample-client.RTT))
+	}
+	h.recordRTT(client.UUID, client.RTT)
+}
+
+// handleTimeSync answers a client's clock sample with the server clock
+func (h *GameHub) handleTimeSync(client *types.Client, msg *types.TimeSyncMessage) {
+	// Views exist only for registered clients, whose Send channel is still open
+	if _, ok := h.views[client]; !ok {
+		return
+	}
+	h.sendToClient(client, types.TimeSyncReplyMessage{
+		Type:       string(types.TimeSyncReplyMsg),
+		ClientTime: msg.ClientTime,
+		ServerTime: types.ServerTime(time.Now()),
+		RTT:        client.RTT.Seconds(),
+	})
+}
+
+// recordRTT publishes a player's RTT to the statistics
+func (h *GameHub) recordRTT(playerID string, rtt time.Duration) {
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	h.stats.PlayerRTT[playerID] = rtt
+}
+
+// lastRTT returns the RTT last measured for a player, zero if none
+func (h *GameHub) lastRTT(playerID string) time.Duration {
+	h.stats.mu.RLock()
+	defer h.stats.mu.RUnlock()
+	return h.stats.PlayerRTT[playerID]
+}
+
+// forgetRTT drops a departed player from the statistics
+func (h *GameHub) forgetRTT(playerID string) {
+	h.stats.mu.Lock()
+	defer h.stats.mu.Unlock()
+	delete(h.stats.PlayerRTT, playerID)
+}
+
+// Pong hands a ping answer, read at receivedAt, to the game loop
+func (h *GameHub) Pong(c *types.Client, id uint32, receivedAt time.Time) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "pong", Client: c, Data: pong{id: id, receivedAt: receivedAt}}:
+	default:
+		log.Printf("Client action channel full, dropping pong from %s", c.UUID)
+	}
+}
+
+// TimeSync queues a client's clock sample to be answered by the game loop
+func (h *GameHub) TimeSync(c *types.Client, msg *types.TimeSyncMessage) {
+	select {
+	case h.clientAction <- &types.ClientAction{Type: "timeSync", Client: c, Data: msg}:
+	default:
+		log.Printf("Client action channel full, dropping time sync from %s", c.UUID)
+	}
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"testing"
+	"time"
+)
+
+// pingRound sends a client the next ping at now and answers it after rtt, as the
+// WritePump and ReadPump would; it reports whether a ping was sent
+func pingRound(h *GameHub, client *types.Client, now time.Time, rtt time.Duration) bool {
+	h.sendPings(now)
+	select {
+	case ping := <-client.Ping:
+		client.MarkPingWritten(ping.ID, now)
+		h.handlePong(client, pong{id: ping.ID, receivedAt: now.Add(rtt)})
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRTTFromPings(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureTimeSync)
+	other := addClient(h, "p2", types.EncodingJSON)
+	interval := h.config.TimeSyncInterval
+	now := time.Now()
+
+	if !pingRound(h, client, now, 100*time.Millisecond) {
+		t.Fatal("no ping sent to a time sync client")
+	}
+	if client.RTT != 100*time.Millisecond {
+		t.Errorf("first sample gave RTT %v, want 100ms", client.RTT)
+	}
+	if len(other.Ping) != 0 {
+		t.Error("pinged a client without time sync")
+	}
+
+	if pingRound(h, client, now.Add(interval/2), time.Second) {
+		t.Error("pinged again before TimeSyncInterval")
+	}
+
+	// Later samples are smoothed
+	if !pingRound(h, client, now.Add(interval), 200*time.Millisecond) {
+		t.Fatal("no ping after TimeSyncInterval")
+	}
+	if want := 112500 * time.Microsecond; client.RTT != want {
+		t.Errorf("smoothed RTT %v, want %v", client.RTT, want)
+	}
+	if got := h.lastRTT("p1"); got != client.RTT {
+		t.Errorf("stats RTT %v, want %v", got, client.RTT)
+	}
+}
+
+func TestStalePongsAreIgnored(t *testing.T) {
+	h := newTestHub(t, nil)
+	client := addClient(h, "p1", types.EncodingJSON, types.FeatureTimeSync)
+	now := time.Now()
+
+	h.sendPings(now)
+	ping := <-client.Ping
+	client.MarkPingWritten(ping.ID, now)
+
+	h.handlePong(client, pong{id: ping.ID - 1, receivedAt: now.Add(time.Second)})
+	if client.RTT != 0 {
+		t.Fatalf("answer to an older ping gave RTT %v", client.RTT)
+	}
+
+	h.handlePong(client, pong{id: ping.ID, receivedAt: now.Add(50 * time.Millisecond)})
+	h.handlePong(client, pong{id: ping.ID, receivedAt: now.Add(time.Second)})
+	if client.RTT != 50*time.Millisecond {
+		t.Errorf("RTT %v after a repeated pong, want the first answer's 50ms", client.RTT)
+	}
+}
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"fmt"
+	"game-server-v1/pkg/types"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// equipWeapon gives a player a weapon with a full magazine
+func (h *GameHub) equipWeapon(player *types.Player, name string) bool {
+	weapon, ok := h.config.Weapons[name]
+	if !ok {
+		return false
+	}
+
+	player.Weapon = name
+	player.Ammo = weapon.MagazineSize
+	player.Reloading = false
+	player.NextFireAt = time.Time{}
+	return true
+}
+
+// switchWeapon equips another weapon for a client's player. The weapon has to be
+// drawn first, so switching never fires or reloads faster than staying put.
+func (h *GameHub) switchWeapon(client *types.Client, name string) {
+	// Views exist only for registered clients, whose Send channel is still open
+	if _, ok := h.views[client]; !ok || client.Player == nil {
+		return
+	}
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	player, ok := h.state.Players[client.Player.ID]
+	if !ok || !player.IsAlive || player.Weapon == name {
+		return
+	}
+
+	weapon, ok := h.config.Weapons[name]
+	if !ok {
+		h.sendToClient(client, types.ErrorMessage{
+			Type:    string(types.ErrorMsg),
+			Code:    types.ErrorCodeBadRequest,
+			Message: fmt.Sprintf("unknown weapon %q", name),
+		})
+		return
+	}
+
+	now := time.Now()
+	readyAt := now.Add(weapon.ReloadTime)
+	if player.NextFireAt.After(readyAt) {
+		readyAt = player.NextFireAt
+	}
+	h.equipWeapon(player, name)
+	player.NextFireAt = readyAt
+}
+
+// fireWeapon spawns a projectile from a player's equipped weapon if cooldown and ammo allow
+func (h *GameHub) fireWeapon(client *types.Client, msg *types.ShootMessage) {
+	if client.Player == nil {
+		log.Printf("Client %s fired without a player", client.UUID)
+		return
+	}
+
+	// Reject directionless shots
+	length := math.Hypot(msg.DirX, msg.DirY)
+	if length == 0 || math.IsNaN(length) || math.IsInf(length, 0) {
+		return
+	}
+
+	now := time.Now()
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	player, ok := h.state.Players[client.Player.ID]
+	if !ok || !player.IsAlive {
+		return
+	}
+
+	weapon, ok := h.config.Weapons[player.Weapon]
+	if !ok {
+		log.Printf("Player %s has unknown weapon %q", player.ID, player.Weapon)
+		return
+	}
+
+	if player.Reloading || now.Before(player.NextFireAt) {
+		return
+	}
+	if weapon.MagazineSize > 0 && player.Ammo <= 0 {
+		h.startReload(player, weapon, now)
+		return
+	}
+
+	// Apply spread as a random deviation within the weapon's cone
+	angle := math.Atan2(msg.DirY, msg.DirX)
+	if weapon.Spread > 0 {
+		angle += (rand.Float64() - 0.5) * weapon.Spread
+	}
+
+	projectile := NewProjectile(player, weapon, math.Cos(angle), math.Sin(angle), now)
+	projectile.Rewind = h.rewindDuration(client, msg.Timestamp, now)
+	projectile.NetID = h.allocNetID()
+	h.state.Projectiles[projectile.ID] = projectile
+
+	if weapon.FireRate > 0 {
+		player.NextFireAt = now.Add(time.Duration(float64(time.Second) / weapon.FireRate))
+	}
+	if weapon.MagazineSize > 0 {
+		player.Ammo--
+		if player.Ammo == 0 {
+			h.startReload(player, weapon, now)
+		}
+	}
+}
+
+// requestReload starts a manual reload for a client's player
+func (h *GameHub) requestReload(client *types.Client) {
+	if client.Player == nil {
+		return
+	}
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	player, ok := h.state.Players[client.Player.ID]
+	if !ok || !player.IsAlive {
+		return
+	}
+
+	weapon, ok := h.config.Weapons[player.Weapon]
+	if !ok || player.Ammo >= weapon.MagazineSize {
+		return
+	}
+
+	h.startReload(player, weapon, time.Now())
+}
+
+// startReload begins reloading unless one is already in progress.
+// Caller must hold the GameState lock.
+func (h *GameHub) startReload(player *types.Player, weapon types.WeaponConfig, now time.Time) {
+	if player.Reloading {
+		return
+	}
+	player.Reloading = true
+	player.ReloadDoneAt = now.Add(weapon.ReloadTime)
+}
+
+// updateWeapons completes finished reloads.
+// Caller must hold the GameState lock.
+func (h *GameHub) updateWeapons(now time.Time) {
+	for _, player := range h.state.Players {
+		if !player.Reloading || now.Before(player.ReloadDoneAt) {
+			continue
+		}
+		player.Reloading = false
+		if weapon, ok := h.config.Weapons[player.Weapon]; ok {
+			player.Ammo = weapon.MagazineSize
+		}
+	}
+}
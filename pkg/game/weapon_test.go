@@ -0,0 +1,156 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"testing"
+	"time"
+)
+
+// newArmedHub creates a hub with a small-magazine pistol and a slow launcher, and one player holding the pistol
+func newArmedHub(t *testing.T) (*GameHub, *types.Client) {
+	t.Helper()
+	config := types.GetDefaultConfig()
+	config.Weapons = map[string]types.WeaponConfig{
+		"pistol":   {FireRate: 10, ProjectileSpeed: 20, Damage: 10, Radius: 0.25, Lifetime: time.Second, MagazineSize: 3, ReloadTime: time.Second},
+		"launcher": {FireRate: 0.5, ProjectileSpeed: 10, Damage: 50, Radius: 0.5, Lifetime: time.Second, MagazineSize: 1, ReloadTime: 2 * time.Second},
+	}
+	config.DefaultWeapon = "pistol"
+	h := newTestHub(t, config)
+	return h, addClient(h, "p1", types.EncodingJSON)
+}
+
+// shoot fires once to the right and reports whether a projectile was spawned
+func shoot(h *GameHub, client *types.Client) bool {
+	before := len(h.state.Projectiles)
+	h.fireWeapon(client, &types.ShootMessage{Type: string(types.ShootMsg), DirX: 1})
+	return len(h.state.Projectiles) > before
+}
+
+// elapse advances a player's weapon timers by d and runs the weapon update of the next tick
+func elapse(h *GameHub, player *types.Player, d time.Duration) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	player.NextFireAt = player.NextFireAt.Add(-d)
+	player.ReloadDoneAt = player.ReloadDoneAt.Add(-d)
+	h.updateWeapons(time.Now())
+}
+
+func TestFireWeaponCooldownAndAmmo(t *testing.T) {
+	h, client := newArmedHub(t)
+	player := client.Player
+
+	if !shoot(h, client) {
+		t.Fatal("first shot did not fire")
+	}
+	if player.Ammo != 2 {
+		t.Errorf("ammo = %d after one shot, want 2", player.Ammo)
+	}
+	if shoot(h, client) {
+		t.Error("fired again within the cooldown")
+	}
+
+	elapse(h, player, 50*time.Millisecond)
+	if shoot(h, client) {
+		t.Error("fired halfway through the cooldown")
+	}
+	elapse(h, player, 50*time.Millisecond)
+	if !shoot(h, client) {
+		t.Error("did not fire once the cooldown elapsed")
+	}
+	if player.Ammo != 1 {
+		t.Errorf("ammo = %d after two shots, want 1", player.Ammo)
+	}
+}
+
+func TestFireWeaponReloadsEmptyMagazine(t *testing.T) {
+	h, client := newArmedHub(t)
+	player := client.Player
+
+	for i := 0; i < 3; i++ {
+		if !shoot(h, client) {
+			t.Fatalf("shot %d did not fire", i+1)
+		}
+		elapse(h, player, 100*time.Millisecond)
+	}
+	if player.Ammo != 0 || !player.Reloading {
+		t.Fatalf("ammo = %d, reloading = %v after emptying the magazine", player.Ammo, player.Reloading)
+	}
+
+	// The last shot started a one-second reload, 100ms of which has passed
+	elapse(h, player, 800*time.Millisecond)
+	if shoot(h, client) {
+		t.Error("fired while reloading")
+	}
+	if !player.Reloading || player.Ammo != 0 {
+		t.Errorf("reload finished early: ammo = %d, reloading = %v", player.Ammo, player.Reloading)
+	}
+
+	elapse(h, player, 100*time.Millisecond)
+	if player.Reloading || player.Ammo != 3 {
+		t.Fatalf("reload not finished after its reload time: ammo = %d, reloading = %v", player.Ammo, player.Reloading)
+	}
+	if !shoot(h, client) {
+		t.Error("did not fire after reloading")
+	}
+}
+
+func TestRequestReload(t *testing.T) {
+	h, client := newArmedHub(t)
+	player := client.Player
+
+	h.requestReload(client)
+	if player.Reloading {
+		t.Fatal("reloaded a full magazine")
+	}
+
+	shoot(h, client)
+	h.requestReload(client)
+	if !player.Reloading {
+		t.Fatal("did not reload a partly used magazine")
+	}
+	elapse(h, player, time.Second)
+	if player.Reloading || player.Ammo != 3 {
+		t.Errorf("ammo = %d, reloading = %v after the reload time", player.Ammo, player.Reloading)
+	}
+}
+
+func TestSwitchWeapon(t *testing.T) {
+	h, client := newArmedHub(t)
+	player := client.Player
+	shoot(h, client)
+
+	h.switchWeapon(client, "launcher")
+	if player.Weapon != "launcher" || player.Ammo != 1 {
+		t.Fatalf("holding %s with %d rounds, want a loaded launcher", player.Weapon, player.Ammo)
+	}
+
+	// Drawing takes the launcher's reload time
+	elapse(h, player, 1900*time.Millisecond)
+	if shoot(h, client) {
+		t.Error("fired before the weapon was drawn")
+	}
+	elapse(h, player, 100*time.Millisecond)
+	if !shoot(h, client) {
+		t.Error("did not fire once the weapon was drawn")
+	}
+
+	// Switching away cannot cut the launcher's two-second cooldown short
+	h.switchWeapon(client, "pistol")
+	if player.Weapon != "pistol" || player.Ammo != 3 || player.Reloading {
+		t.Fatalf("holding %s with %d rounds, reloading = %v, want a loaded pistol", player.Weapon, player.Ammo, player.Reloading)
+	}
+	elapse(h, player, 1500*time.Millisecond)
+	if shoot(h, client) {
+		t.Error("switching weapons skipped the cooldown")
+	}
+
+	sentMessages(t, client)
+	h.switchWeapon(client, "railgun")
+	if player.Weapon != "pistol" {
+		t.Errorf("switched to an unknown weapon: %s", player.Weapon)
+	}
+	if msgs := sentMessages(t, client); len(msgs[string(types.ErrorMsg)]) != 1 {
+		t.Errorf("unknown weapon sent %v, want an error", keys(msgs))
+	}
+}
@@ -1 +1,227 @@
 package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-server-v1/pkg/types"
+	"math"
+	"os"
+)
+
+// Box is an axis-aligned rectangular obstacle
+type Box struct {
+	MinX float64 `json:"minX"`
+	MinY float64 `json:"minY"`
+	MaxX float64 `json:"maxX"`
+	MaxY float64 `json:"maxY"`
+}
+
+// Circle is a round obstacle
+type Circle struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+// World holds the static geometry of the current map
+type World struct {
+	Bounds      types.WorldBounds  `json:"bounds"`
+	SpawnPoints []types.SpawnPoint `json:"spawnPoints"`
+	Boxes       []Box              `json:"boxes"`
+	Circles     []Circle           `json:"circles"`
+
+	// index holds the obstacles for broad-phase collision queries
+	index *SpatialGrid
+}
+
+// maxResolveIterations bounds how many times overlapping obstacles are pushed apart per move
+const maxResolveIterations = 4
+
+// NewWorld creates an empty world from the configured bounds and spawn points
+func NewWorld(config *types.GameConfig) *World {
+	return &World{
+		Bounds:      config.WorldBounds,
+		SpawnPoints: config.SpawnPoints,
+	}
+}
+
+// LoadWorld reads a JSON map file. Bounds and spawn points missing from the file come from config.
+func LoadWorld(path string, config *types.GameConfig) (*World, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading map %s: %w", path, err)
+	}
+
+	world := NewWorld(config)
+	if err := json.Unmarshal(data, world); err != nil {
+		return nil, fmt.Errorf("parsing map %s: %w", path, err)
+	}
+
+	if world.Bounds.MinX >= world.Bounds.MaxX || world.Bounds.MinY >= world.Bounds.MaxY {
+		return nil, fmt.Errorf("map %s: bounds are empty", path)
+	}
+	for i, box := range world.Boxes {
+		if box.MinX > box.MaxX || box.MinY > box.MaxY {
+			return nil, fmt.Errorf("map %s: box %d has min greater than max", path, i)
+		}
+	}
+	for i, circle := range world.Circles {
+		if circle.Radius <= 0 {
+			return nil, fmt.Errorf("map %s: circle %d has no radius", path, i)
+		}
+	}
+
+	return world, nil
+}
+
+// BuildIndex buckets the obstacles into a spatial grid; call it after the geometry changes
+func (w *World) BuildIndex(cellSize float64) {
+	w.index = NewSpatialGrid(cellSize)
+	for i, box := range w.Boxes {
+		w.index.Insert(SpatialEntry{
+			Kind:   EntityBox,
+			Index:  i,
+			X:      (box.MinX + box.MaxX) / 2,
+			Y:      (box.MinY + box.MaxY) / 2,
+			Radius: math.Hypot(box.MaxX-box.MinX, box.MaxY-box.MinY) / 2,
+		})
+	}
+	for i, circle := range w.Circles {
+		w.index.Insert(SpatialEntry{
+			Kind:   EntityCircle,
+			Index:  i,
+			X:      circle.X,
+			Y:      circle.Y,
+			Radius: circle.Radius,
+		})
+	}
+}
+
+// forEachObstacleNear calls fn for obstacles that may overlap the circle until fn returns false
+func (w *World) forEachObstacleNear(x, y, radius float64, fn func(kind EntityKind, index int) bool) {
+	if w.index != nil {
+		w.index.QueryRadius(x, y, radius, func(e *SpatialEntry) bool {
+			return fn(e.Kind, e.Index)
+		})
+		return
+	}
+
+	for i := range w.Boxes {
+		if !fn(EntityBox, i) {
+			return
+		}
+	}
+	for i := range w.Circles {
+		if !fn(EntityCircle, i) {
+			return
+		}
+	}
+}
+
+// ResolveCircle pushes a circle out of any obstacles it overlaps and keeps it inside the bounds.
+// Pushing along the contact normal leaves the tangential part of a move intact, so movers slide along walls.
+func (w *World) ResolveCircle(x, y, radius float64) (float64, float64) {
+	for i := 0; i < maxResolveIterations; i++ {
+		moved := false
+
+		// Candidates are gathered where the circle starts this pass, with a one-radius margin.
+		// A push can carry it further, onto obstacles outside that set; the next pass's
+		// query starts from the new position and finds them.
+		w.forEachObstacleNear(x, y, radius*2, func(kind EntityKind, index int) bool {
+			var nx, ny float64
+			var ok bool
+			if kind == EntityBox {
+				nx, ny, ok = w.Boxes[index].pushOut(x, y, radius)
+			} else {
+				nx, ny, ok = w.Circles[index].pushOut(x, y, radius)
+			}
+			if ok {
+				x, y = nx, ny
+				moved = true
+			}
+			return true
+		})
+
+		if !moved {
+			break
+		}
+	}
+
+	return w.Bounds.ClampPosition(x, y)
+}
+
+// OverlapsCircle reports whether a circle touches any obstacle
+func (w *World) OverlapsCircle(x, y, radius float64) bool {
+	hit := false
+	w.forEachObstacleNear(x, y, radius, func(kind EntityKind, index int) bool {
+		if kind == EntityBox {
+			hit = w.Boxes[index].overlaps(x, y, radius)
+		} else {
+			hit = w.Circles[index].overlaps(x, y, radius)
+		}
+		return !hit
+	})
+	return hit
+}
+
+// closestPoint returns the point on the box nearest to (x, y)
+func (b Box) closestPoint(x, y float64) (float64, float64) {
+	return math.Max(b.MinX, math.Min(x, b.MaxX)), math.Max(b.MinY, math.Min(y, b.MaxY))
+}
+
+func (b Box) overlaps(x, y, radius float64) bool {
+	cx, cy := b.closestPoint(x, y)
+	return math.Hypot(x-cx, y-cy) < radius
+}
+
+// pushOut moves a circle to the nearest position where it no longer overlaps the box
+func (b Box) pushOut(x, y, radius float64) (float64, float64, bool) {
+	cx, cy := b.closestPoint(x, y)
+	dx, dy := x-cx, y-cy
+	dist := math.Hypot(dx, dy)
+
+	if dist >= radius {
+		return x, y, false
+	}
+
+	if dist > 0 {
+		push := radius - dist
+		return x + dx/dist*push, y + dy/dist*push, true
+	}
+
+	// Center is inside the box: leave through the nearest face
+	left, right := x-b.MinX, b.MaxX-x
+	down, up := y-b.MinY, b.MaxY-y
+	switch math.Min(math.Min(left, right), math.Min(down, up)) {
+	case left:
+		return b.MinX - radius, y, true
+	case right:
+		return b.MaxX + radius, y, true
+	case down:
+		return x, b.MinY - radius, true
+	default:
+		return x, b.MaxY + radius, true
+	}
+}
+
+func (c Circle) overlaps(x, y, radius float64) bool {
+	return math.Hypot(x-c.X, y-c.Y) < c.Radius+radius
+}
+
+// pushOut moves a circle to the nearest position where it no longer overlaps this circle
+func (c Circle) pushOut(x, y, radius float64) (float64, float64, bool) {
+	dx, dy := x-c.X, y-c.Y
+	dist := math.Hypot(dx, dy)
+	reach := c.Radius + radius
+
+	if dist >= reach {
+		return x, y, false
+	}
+
+	// Exactly centred: pick an arbitrary direction
+	if dist == 0 {
+		return c.X + reach, y, true
+	}
+
+	return c.X + dx/dist*reach, c.Y + dy/dist*reach, true
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"game-server-v1/pkg/types"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testWorld is a 20x20 room with a wall along x = 5, a box above the centre and a pillar at (-5, 0)
+func testWorld() *World {
+	return &World{
+		Bounds:  types.WorldBounds{MinX: -10, MinY: -10, MaxX: 10, MaxY: 10},
+		Boxes:   []Box{{MinX: 5, MinY: -10, MaxX: 6, MaxY: 10}, {MinX: -2, MinY: 4, MaxX: 2, MaxY: 5}},
+		Circles: []Circle{{X: -5, Y: 0, Radius: 1}},
+	}
+}
+
+func TestResolveCircle(t *testing.T) {
+	const radius = 0.5
+
+	tests := []struct {
+		name         string
+		x, y         float64 // where the move ends before collision
+		wantX, wantY float64
+	}{
+		{"open floor", 0, 0, 0, 0},
+		{"slides along a wall", 4.8, 3, 4.5, 3},
+		{"centre inside a wall leaves by the nearest face", 5.2, -1, 4.5, -1},
+		{"slides around a pillar", -5, 1.3, -5, 1.5},
+		{"pushed off the underside of a box", 1.8, 3.8, 1.8, 3.5},
+		{"kept inside the bounds", 0, -10.5, 0, -10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The spatial index must find the same obstacles as the linear scan
+			for _, indexed := range []bool{false, true} {
+				world := testWorld()
+				if indexed {
+					world.BuildIndex(2)
+				}
+				x, y := world.ResolveCircle(tt.x, tt.y, radius)
+				if math.Abs(x-tt.wantX) > 1e-9 || math.Abs(y-tt.wantY) > 1e-9 {
+					t.Errorf("indexed=%v: resolved to (%g, %g), want (%g, %g)", indexed, x, y, tt.wantX, tt.wantY)
+				}
+				if world.OverlapsCircle(x, y, radius) {
+					t.Errorf("indexed=%v: still overlapping at (%g, %g)", indexed, x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadWorld(t *testing.T) {
+	config := types.GetDefaultConfig()
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"valid", `{"boxes":[{"minX":0,"minY":0,"maxX":1,"maxY":1}],"circles":[{"x":3,"y":3,"radius":1}]}`, ""},
+		{"not JSON", `{"boxes":`, "parsing map"},
+		{"empty bounds", `{"bounds":{"minX":5,"minY":0,"maxX":5,"maxY":10}}`, "bounds are empty"},
+		{"inverted box", `{"boxes":[{"minX":0,"minY":0,"maxX":1,"maxY":1},{"minX":2,"minY":3,"maxX":4,"maxY":1}]}`, "box 1 has min greater than max"},
+		{"circle without radius", `{"circles":[{"x":1,"y":1}]}`, "circle 0 has no radius"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "map.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			world, err := LoadWorld(path, config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Bounds and spawn points the file leaves out come from the config
+			if world.Bounds != config.WorldBounds || len(world.SpawnPoints) != len(config.SpawnPoints) {
+				t.Errorf("bounds %+v and %d spawn points, want the config's", world.Bounds, len(world.SpawnPoints))
+			}
+		})
+	}
+
+	if _, err := LoadWorld(filepath.Join(t.TempDir(), "missing.json"), config); err == nil || !strings.Contains(err.Error(), "reading map") {
+		t.Errorf("missing file: %v, want a read error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/transport"
+	"game-server-v1/pkg/types"
+	"log"
+	"maps"
+	"slices"
+	"time"
+)
+
+var errHandshakeTimeout = errors.New("no hello received in time")
+
+// handshakeError is a refused handshake, reported to the client before closing
+type handshakeError struct {
+	code    int
+	message string
+}
+
+func (e *handshakeError) Error() string { return e.message }
+
+// readHello waits for the hello that must open every connection. On a timeout
+// the read stays pending until the caller closes the connection.
+func readHello(conn transport.Conn) (*types.HelloMessage, error) {
+	type result struct {
+		msgType transport.MessageType
+		data    []byte
+		err     error
+	}
+	read := make(chan result, 1)
+	go func() {
+		msgType, data, err := conn.ReadMessage()
+		read <- result{msgType, data, err}
+	}()
+
+	var r result
+	select {
+	case r = <-read:
+	case <-time.After(types.HandshakeTimeout):
+		return nil, errHandshakeTimeout
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var hello types.HelloMessage
+	if r.msgType != transport.TextMessage || json.Unmarshal(r.data, &hello) != nil || hello.Type != string(types.HelloMsg) {
+		return nil, &handshakeError{code: types.ErrorCodeBadRequest, message: "expected hello as the first message"}
+	}
+	return &hello, nil
+}
+
+// negotiate checks a hello against what the server supports and picks the
+// encoding and features for the connection. defaultEncoding is the transport's choice.
+func negotiate(hello *types.HelloMessage, defaultEncoding string) (string, map[string]bool, error) {
+	if hello.ProtocolVersion < types.MinProtocolVersion || hello.ProtocolVersion > types.ProtocolVersion {
+		return "", nil, &handshakeError{
+			code: types.ErrorCodeUnsupportedVersion,
+			message: fmt.Sprintf("unsupported protocol version %d, server speaks %d to %d",
+				hello.ProtocolVersion, types.MinProtocolVersion, types.ProtocolVersion),
+		}
+	}
+
+	encoding := defaultEncoding
+	if len(hello.Encodings) > 0 {
+		i := slices.IndexFunc(hello.Encodings, func(e string) bool {
+			return e == types.EncodingJSON || e == types.EncodingBinary
+		})
+		if i < 0 {
+			return "", nil, &handshakeError{
+				code:    types.ErrorCodeBadRequest,
+				message: fmt.Sprintf("none of the encodings %v are supported", hello.Encodings),
+			}
+		}
+		encoding = hello.Encodings[i]
+	}
+
+	features := make(map[string]bool)
+	for _, f := range hello.Features {
+		if slices.Contains(types.ServerFeatures, f) {
+			features[f] = true
+		}
+	}
+	return encoding, features, nil
+}
+
+// negotiateBandwidth picks a client's snapshot budget: the lower of the server cap
+// and what the client asked for, where 0 on either side means no limit
+func negotiateBandwidth(serverMax, requested int) int {
+	if requested <= 0 {
+		return serverMax
+	}
+	if serverMax <= 0 {
+		return requested
+	}
+	return min(serverMax, requested)
+}
+
+// negotiateSnapshotRate lets a client lower its snapshot rate, never raise it
+func negotiateSnapshotRate(serverRate, requested int) int {
+	if requested <= 0 {
+		return serverRate
+	}
+	return min(serverRate, requested)
+}
+
+// sendWelcome tells the client what was agreed, before any other message
+func sendWelcome(hub *game.GameHub, client *types.Client) error {
+	config := hub.GetConfig()
+	welcome := types.WelcomeMessage{
+		Type:            string(types.WelcomeMsg),
+		ProtocolVersion: types.ProtocolVersion,
+		Encoding:        client.Encoding,
+		Features:        []string{},
+		MaxBandwidth:    client.Bandwidth,
+		TickRate:        config.TickRate,
+		TickInterval:    config.TickInterval.Seconds(),
+		SnapshotRate:    client.SnapshotRate,
+		WorldBounds:     hub.GetWorld().Bounds,
+		ServerTime:      float64(time.Now().UnixNano()) / 1e9,
+		Weapons:         slices.Sorted(maps.Keys(config.Weapons)),
+	}
+	for _, f := range types.ServerFeatures {
+		if client.HasFeature(f) {
+			welcome.Features = append(welcome.Features, f)
+		}
+	}
+
+	data, err := json.Marshal(welcome)
+	if err != nil {
+		return err
+	}
+	return client.Conn.WriteMessage(transport.TextMessage, data)
+}
+
+// rejectHandshake reports why a connection was refused and closes it
+func rejectHandshake(conn transport.Conn, err error) {
+	code := types.ErrorCodeBadRequest
+	var refused *handshakeError
+	if errors.As(err, &refused) {
+		code = refused.code
+	}
+	log.Printf("Handshake with %s failed: %v", conn.RemoteAddr(), err)
+
+	data, _ := json.Marshal(types.ErrorMessage{
+		Type:    string(types.ErrorMsg),
+		Code:    code,
+		Message: err.Error(),
+	})
+	conn.WriteMessage(transport.TextMessage, data)
+	conn.SendClose(types.CloseProtocolError, err.Error())
+	conn.Close()
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"game-server-v1/pkg/types"
+	"math"
+	"time"
+)
+
+// tokenBucket admits bursts of up to burst messages, refilling at rate per second
+type tokenBucket struct {
+	tokens float64
+	rate   float64
+	burst  float64
+	last   time.Time
+}
+
+func newTokenBucket(limit types.RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(limit.Burst),
+		rate:   limit.Rate,
+		burst:  float64(limit.Burst),
+		last:   now,
+	}
+}
+
+// allow takes one token if available
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateVerdict is what to do with one incoming message
+type rateVerdict int
+
+const (
+	rateAllowed    rateVerdict = iota
+	rateWarn                   // drop it and warn the client
+	rateDropped                // drop it silently, the client was already warned
+	rateDisconnect             // the client keeps flooding, close the connection
+)
+
+// rateLimiter tracks one connection's message budgets. It is only used by that
+// connection's ReadPump, so it needs no locking.
+type rateLimiter struct {
+	limits  map[string]types.RateLimit
+	buckets map[string]*tokenBucket
+
+	maxViolations int
+	window        time.Duration
+	windowStart   time.Time
+	violations    int
+}
+
+func newRateLimiter(config *types.GameConfig) *rateLimiter {
+	return &rateLimiter{
+		limits:        config.RateLimits,
+		buckets:       make(map[string]*tokenBucket),
+		maxViolations: config.MaxRateViolations,
+		window:        config.RateViolationWindow,
+	}
+}
+
+// check charges one message of the given type against the connection's budget
+func (l *rateLimiter) check(msgType string, now time.Time) rateVerdict {
+	key := msgType
+	limit, ok := l.limits[key]
+	if !ok {
+		key = types.DefaultRateLimitKey
+		if limit, ok = l.limits[key]; !ok {
+			return rateAllowed
+		}
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(limit, now)
+		l.buckets[key] = bucket
+	}
+	if bucket.allow(now) {
+		return rateAllowed
+	}
+
+	// Count violations per window; warn once at the start of each
+	if now.Sub(l.windowStart) > l.window {
+		l.windowStart = now
+		l.violations = 0
+	}
+	l.violations++
+
+	if l.maxViolations > 0 && l.violations >= l.maxViolations {
+		return rateDisconnect
+	}
+	if l.violations == 1 {
+		return rateWarn
+	}
+	return rateDropped
+}
@@ -0,0 +1,92 @@
+package network
+
+import (
+	"errors"
+	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/transport"
+	"game-server-v1/pkg/types"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrHubStopped is returned by Serve when the hub no longer accepts clients
+var ErrHubStopped = errors.New("network: hub stopped")
+
+// Serve performs the hello/welcome handshake on a connection from any
+// transport, registers it with hub and runs its pumps until the connection
+// ends, including flushing queued messages. encoding is the transport's
+// default, and a valid resumeToken reclaims the player left behind by a
+// dropped connection; the hello may override both.
+func Serve(hub *game.GameHub, conn transport.Conn, encoding, resumeToken string) error {
+	var features map[string]bool
+	hello, err := readHello(conn)
+	if err == nil {
+		encoding, features, err = negotiate(hello, encoding)
+	}
+	if err != nil {
+		rejectHandshake(conn, err)
+		return err
+	}
+	if hello.ResumeToken != "" {
+		resumeToken = hello.ResumeToken
+	}
+
+	id := uuid.New().String()
+	resumed := false
+	if resumeToken != "" {
+		if playerID, ok := hub.ResumeSession(resumeToken); ok {
+			id = playerID
+			resumed = true
+		} else {
+			log.Println("Unknown or expired resume token, starting new session")
+		}
+	}
+
+	client := &types.Client{
+		UUID:     id,
+		Conn:     conn,
+		Send:     make(chan []byte, 256),
+		Snapshot: make(chan []byte, 1),
+		Ping:     make(chan types.PingMessage, 1),
+		LastSeen: time.Now(),
+		Encoding: encoding,
+		Features: features,
+
+		Bandwidth:    negotiateBandwidth(hub.GetConfig().MaxBandwidth, hello.MaxBandwidth),
+		SnapshotRate: negotiateSnapshotRate(hub.GetConfig().MaxSnapshotRate(), hello.SnapshotRate),
+	}
+
+	log.Printf("New client connected %s from %s", client.UUID, conn.RemoteAddr())
+
+	// Until it registers, the connection holds the claim on a resumed session
+	releaseSession := func() {
+		if resumed {
+			hub.ReleaseSession(id, resumeToken)
+		}
+	}
+
+	if err := sendWelcome(hub, client); err != nil {
+		releaseSession()
+		conn.Close()
+		return err
+	}
+
+	if !hub.Register(client) {
+		releaseSession()
+		rejectConn(conn, "server shutting down")
+		return ErrHubStopped
+	}
+
+	// Wait for the WritePump too, so shutdown only completes once queued messages are flushed
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		WritePump(client)
+	}()
+	ReadPump(hub, client)
+	<-writerDone
+
+	return nil
+}
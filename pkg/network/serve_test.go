@@ -0,0 +1,286 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/transport"
+	"game-server-v1/pkg/types"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// testTimeout bounds how long a test waits for the server
+const testTimeout = 5 * time.Second
+
+// testMessage holds the fields the tests look at in any server message
+type testMessage struct {
+	Type        string   `json:"type"`
+	Encoding    string   `json:"encoding"`
+	Features    []string `json:"features"`
+	PlayerID    string   `json:"playerId"`
+	ResumeToken string   `json:"resumeToken"`
+	Resumed     bool     `json:"resumed"`
+	Tick        uint64   `json:"tick"`
+	BaseTick    uint64   `json:"baseTick"`
+	Code        int      `json:"code"`
+}
+
+// testClient is the client end of a pipe whose server end runs Serve
+type testClient struct {
+	t      *testing.T
+	conn   transport.Conn
+	msgs   chan testMessage
+	err    error // why the connection ended, set before msgs is closed
+	served chan error
+}
+
+// startHub runs a hub with config until the test ends
+func startHub(t *testing.T, config *types.GameConfig) *game.GameHub {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	hub := game.NewGameHub(config)
+	hub.Start(context.Background())
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+// newHello is a valid hello for a JSON client
+func newHello(features ...string) types.HelloMessage {
+	return types.HelloMessage{
+		Type:            string(types.HelloMsg),
+		ProtocolVersion: types.ProtocolVersion,
+		Encodings:       []string{types.EncodingJSON},
+		Features:        features,
+	}
+}
+
+// connect serves a new pipe connection on hub and sends hello over it
+func connect(t *testing.T, hub *game.GameHub, hello types.HelloMessage) *testClient {
+	t.Helper()
+	server, conn := transport.Pipe()
+	c := &testClient{
+		t:      t,
+		conn:   conn,
+		msgs:   make(chan testMessage, 1024),
+		served: make(chan error, 1),
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() { c.served <- Serve(hub, server, types.EncodingJSON, "") }()
+	go c.readLoop()
+
+	c.send(hello)
+	return c
+}
+
+func (c *testClient) readLoop() {
+	defer close(c.msgs)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		var msg testMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.err = err
+			return
+		}
+		c.msgs <- msg
+	}
+}
+
+// send writes a message as JSON
+func (c *testClient) send(msg any) {
+	c.t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.WriteMessage(transport.TextMessage, data); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// expect skips messages until one of the given type arrives
+func (c *testClient) expect(msgType types.MessageType) testMessage {
+	c.t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("connection ended waiting for %s: %v", msgType, c.err)
+			}
+			if msg.Type == string(msgType) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("no %s within %v", msgType, testTimeout)
+		}
+	}
+}
+
+// closed waits for the server to end the connection and returns why
+func (c *testClient) closed() error {
+	c.t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case _, ok := <-c.msgs:
+			if !ok {
+				return c.err
+			}
+		case <-timeout:
+			c.t.Fatalf("connection still open after %v", testTimeout)
+		}
+	}
+}
+
+// join completes the handshake and returns the playerId message
+func (c *testClient) join() testMessage {
+	c.t.Helper()
+	c.expect(types.WelcomeMsg)
+	id := c.expect(types.PlayerIDMsg)
+	c.expect(types.GameStateMsg)
+	return id
+}
+
+func TestServeHandshake(t *testing.T) {
+	hub := startHub(t, types.GetDefaultConfig())
+	c := connect(t, hub, newHello(types.FeatureDeltaSnapshots, "unknownFeature"))
+
+	welcome := c.expect(types.WelcomeMsg)
+	if welcome.Encoding != types.EncodingJSON {
+		t.Errorf("welcome encoding = %q, want %q", welcome.Encoding, types.EncodingJSON)
+	}
+	if len(welcome.Features) != 1 || welcome.Features[0] != types.FeatureDeltaSnapshots {
+		t.Errorf("welcome features = %v, want [%s]", welcome.Features, types.FeatureDeltaSnapshots)
+	}
+
+	id := c.expect(types.PlayerIDMsg)
+	if id.PlayerID == "" || id.ResumeToken == "" || id.Resumed {
+		t.Errorf("playerId = %+v, want a new player with a resume token", id)
+	}
+	c.expect(types.GameStateMsg)
+}
+
+func TestServeRejectsUnsupportedVersion(t *testing.T) {
+	hub := startHub(t, types.GetDefaultConfig())
+	hello := newHello()
+	hello.ProtocolVersion = types.ProtocolVersion + 1
+	c := connect(t, hub, hello)
+
+	if msg := c.expect(types.ErrorMsg); msg.Code != types.ErrorCodeUnsupportedVersion {
+		t.Errorf("error code = %d, want %d", msg.Code, types.ErrorCodeUnsupportedVersion)
+	}
+
+	var closeErr *transport.CloseError
+	if err := c.closed(); !errors.As(err, &closeErr) || closeErr.Code != types.CloseProtocolError {
+		t.Errorf("connection ended with %v, want close code %d", err, types.CloseProtocolError)
+	}
+	if err := <-c.served; err == nil {
+		t.Error("Serve returned nil for a refused handshake")
+	}
+	if hub.PlayerCount() != 0 {
+		t.Errorf("refused connection left %d players", hub.PlayerCount())
+	}
+}
+
+func TestServeRejectionReasonFitsCloseFrame(t *testing.T) {
+	hub := startHub(t, types.GetDefaultConfig())
+	hello := newHello()
+	hello.Encodings = []string{strings.Repeat("é", 200)}
+	c := connect(t, hub, hello)
+
+	if msg := c.expect(types.ErrorMsg); msg.Code != types.ErrorCodeBadRequest {
+		t.Errorf("error code = %d, want %d", msg.Code, types.ErrorCodeBadRequest)
+	}
+
+	var closeErr *transport.CloseError
+	if err := c.closed(); !errors.As(err, &closeErr) || closeErr.Code != types.CloseProtocolError {
+		t.Fatalf("connection ended with %v, want close code %d", err, types.CloseProtocolError)
+	}
+	if len(closeErr.Reason) > transport.MaxCloseReason || !utf8.ValidString(closeErr.Reason) {
+		t.Errorf("close reason of %d bytes (valid UTF-8: %v), want at most %d", len(closeErr.Reason), utf8.ValidString(closeErr.Reason), transport.MaxCloseReason)
+	}
+}
+
+func TestServeResume(t *testing.T) {
+	hub := startHub(t, types.GetDefaultConfig())
+	first := connect(t, hub, newHello())
+	id := first.join()
+
+	first.conn.Close()
+	if err := <-first.served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	hello := newHello()
+	hello.ResumeToken = id.ResumeToken
+	second := connect(t, hub, hello)
+	second.expect(types.WelcomeMsg)
+
+	resumed := second.expect(types.PlayerIDMsg)
+	if resumed.PlayerID != id.PlayerID || !resumed.Resumed {
+		t.Fatalf("playerId after resume = %+v, want player %s resumed", resumed, id.PlayerID)
+	}
+	if resumed.ResumeToken == "" || resumed.ResumeToken == id.ResumeToken {
+		t.Errorf("resume token was not replaced: %q", resumed.ResumeToken)
+	}
+	second.expect(types.GameStateMsg)
+
+	if hub.PlayerCount() != 1 {
+		t.Errorf("PlayerCount() = %d after resume, want 1", hub.PlayerCount())
+	}
+}
+
+func TestServeRateLimitDisconnect(t *testing.T) {
+	config := types.GetDefaultConfig()
+	config.RateLimits = map[string]types.RateLimit{
+		string(types.ChatMsg): {Rate: 0.01, Burst: 1},
+	}
+	config.MaxRateViolations = 3
+	hub := startHub(t, config)
+
+	c := connect(t, hub, newHello())
+	c.join()
+
+	for i := 0; i < 20; i++ {
+		if err := c.conn.WriteMessage(transport.TextMessage, []byte(`{"type":"chat","message":"spam"}`)); err != nil {
+			break // the server already hung up
+		}
+	}
+
+	var closeErr *transport.CloseError
+	if err := c.closed(); !errors.As(err, &closeErr) || closeErr.Code != types.ClosePolicyViolation {
+		t.Fatalf("connection ended with %v, want close code %d", err, types.ClosePolicyViolation)
+	}
+}
+
+func TestServeDeltaSnapshotsAfterAck(t *testing.T) {
+	hub := startHub(t, types.GetDefaultConfig())
+	c := connect(t, hub, newHello(types.FeatureDeltaSnapshots))
+	c.join()
+
+	// Without an ack the server has no baseline and keeps sending full snapshots
+	full := c.expect(types.GameStateMsg)
+	c.send(types.SnapshotAckMessage{Type: string(types.SnapshotAckMsg), Tick: full.Tick})
+
+	delta := c.expect(types.GameStateDeltaMsg)
+	if delta.BaseTick != full.Tick {
+		t.Fatalf("delta against tick %d, want the acknowledged tick %d", delta.BaseTick, full.Tick)
+	}
+	if delta.Tick <= full.Tick {
+		t.Errorf("delta tick %d is not after its baseline %d", delta.Tick, full.Tick)
+	}
+}
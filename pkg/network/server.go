@@ -0,0 +1,311 @@
+package network
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/protocol"
+	"game-server-v1/pkg/transport"
+	"game-server-v1/pkg/types"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server accepts WebSocket and UDP connections, routes them to their rooms and
+// drains them on shutdown
+type Server struct {
+	rooms      *game.RoomManager
+	adminToken string
+	http       *http.Server
+	udp        *transport.UDPListener
+
+	// Upgraded connections are hijacked from net/http, so the server tracks them itself
+	mu      sync.Mutex
+	closing bool
+	conns   map[transport.Conn]struct{}
+	active  sync.WaitGroup
+}
+
+// NewServer creates a server listening on addr with the /ws, /stats and /rooms
+// endpoints serving the rooms of rooms. Creating and destroying rooms requires
+// adminToken as a bearer token; an empty token disables both.
+func NewServer(rooms *game.RoomManager, addr, adminToken string) *Server {
+	s := &Server{
+		rooms:      rooms,
+		adminToken: adminToken,
+		conns:      make(map[transport.Conn]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("GET /rooms", s.handleListRooms)
+	mux.HandleFunc("POST /rooms", s.requireAdmin(s.handleCreateRoom))
+	mux.HandleFunc("DELETE /rooms/{id}", s.requireAdmin(s.handleDestroyRoom))
+	s.http = &http.Server{Addr: addr, Handler: mux}
+
+	return s
+}
+
+// ListenAndServe serves until Shutdown is called, then returns http.ErrServerClosed
+func (s *Server) ListenAndServe() error {
+	log.Printf("The game server is running at %s", s.http.Addr)
+	return s.http.ListenAndServe()
+}
+
+// ServeUDP accepts UDP clients on addr until Shutdown. They join the room named
+// in their connect request exactly like WebSocket clients.
+func (s *Server) ServeUDP(addr string) error {
+	listener, err := transport.ListenUDP(addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.udp = listener
+	s.mu.Unlock()
+
+	log.Printf("Accepting UDP clients at %s", listener.Addr())
+
+	for {
+		conn, req, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, transport.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		room, ok := s.rooms.Get(req.Room)
+		if !ok {
+			rejectConn(conn, "unknown room")
+			continue
+		}
+		if !s.track(conn) {
+			rejectConn(conn, "server shutting down")
+			continue
+		}
+
+		encoding := types.EncodingJSON
+		if req.Binary {
+			encoding = types.EncodingBinary
+		}
+
+		go func() {
+			defer s.untrack(conn)
+			Serve(room.Hub, conn, encoding, req.ResumeToken)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, closes every client with a going-away
+// frame after flushing its queued messages, and waits for connections to end.
+// Connections still open when ctx expires are closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	err := s.http.Shutdown(ctx)
+
+	// Stopping the hubs closes every client's Send channel; the pumps flush and close
+	s.rooms.StopAll()
+
+	drained := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		err = ctx.Err()
+	}
+
+	// UDP connections share the listener's socket, so it closes last
+	s.mu.Lock()
+	if s.udp != nil {
+		s.udp.Close()
+	}
+	s.mu.Unlock()
+
+	return err
+}
+
+// track records a new connection. It reports false once shutdown has begun.
+func (s *Server) track(conn transport.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+// untrack forgets a connection whose pumps have both exited
+func (s *Server) untrack(conn transport.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	s.active.Done()
+}
+
+// handleWebSocket upgrades a connection to the room named by ?room= and runs its
+// pumps until it ends
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	room, ok := s.rooms.Get(r.URL.Query().Get("room"))
+	if !ok {
+		http.Error(w, game.ErrRoomNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Upgrader error", err)
+		return
+	}
+
+	conn := transport.NewWebSocketConn(ws, types.WriteWait)
+	if !s.track(conn) {
+		rejectConn(conn, "server shutting down")
+		return
+	}
+	defer s.untrack(conn)
+
+	encoding := types.EncodingJSON
+	if ws.Subprotocol() == protocol.SubprotocolBinary {
+		encoding = types.EncodingBinary
+	}
+
+	// The WritePump's pings keep the read deadline moving
+	ws.SetReadLimit(types.MaxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(types.PongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(types.PongWait))
+	})
+
+	Serve(room.Hub, conn, encoding, r.URL.Query().Get("resume"))
+}
+
+// handleStats reports the statistics of the room named by ?room=, including every player's RTT
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	room, ok := s.rooms.Get(r.URL.Query().Get("room"))
+	if !ok {
+		http.Error(w, game.ErrRoomNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	stats := room.Hub.GetStats()
+	writeJSON(w, http.StatusOK, &stats)
+}
+
+// maxRoomRequestSize bounds a POST /rooms body, which may carry a whole config
+const maxRoomRequestSize = 64 << 10
+
+// createRoomRequest is the body of POST /rooms. Config holds the few GameConfig
+// fields a room may override; both may be omitted.
+type createRoomRequest struct {
+	ID     string               `json:"id"`
+	Config *types.RoomOverrides `json:"config"`
+}
+
+// requireAdmin only lets requests carrying the admin token through to next
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.Error(w, "room management is disabled", http.StatusForbidden)
+			return
+		}
+
+		// Compare digests so the check takes the same time whatever the token's length
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		got, want := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(s.adminToken))
+		if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rooms"`)
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleListRooms lists every room
+func (s *Server) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rooms.List())
+}
+
+// handleCreateRoom creates a room and answers with its id
+func (s *Server) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req createRoomRequest
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRoomRequestSize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			http.Error(w, "invalid room request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	room, err := s.rooms.Create(req.ID, req.Config)
+	switch {
+	case errors.Is(err, game.ErrRoomExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, game.ErrTooManyRooms), errors.Is(err, game.ErrManagerStopped):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]string{"id": room.ID})
+}
+
+// handleDestroyRoom destroys a room, disconnecting its clients
+func (s *Server) handleDestroyRoom(w http.ResponseWriter, r *http.Request) {
+	err := s.rooms.Destroy(r.PathValue("id"))
+	switch {
+	case errors.Is(err, game.ErrRoomNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeJSON answers with v encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// rejectConn closes a fresh connection with a going-away notice
+func rejectConn(conn transport.Conn, reason string) {
+	conn.SendClose(types.CloseGoingAway, reason)
+	conn.Close()
+}
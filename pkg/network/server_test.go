@@ -0,0 +1,113 @@
+package network
+
+import (
+	"context"
+	"game-server-v1/pkg/game"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// startServer serves the room endpoints of a fresh room manager until the test ends
+func startServer(t *testing.T, adminToken string) *httptest.Server {
+	t.Helper()
+
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	rooms := game.NewRoomManager(context.Background(), nil)
+	t.Cleanup(rooms.StopAll)
+
+	srv := httptest.NewServer(NewServer(rooms, "", adminToken).http.Handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// request sends an HTTP request with an optional bearer token and returns the status code
+func request(t *testing.T, srv *httptest.Server, method, path, token, body string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRoomManagementRequiresAdminToken(t *testing.T) {
+	srv := startServer(t, "secret")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"create without a token", http.MethodPost, "/rooms", "", http.StatusUnauthorized},
+		{"create with a wrong token", http.MethodPost, "/rooms", "guess", http.StatusUnauthorized},
+		{"destroy without a token", http.MethodDelete, "/rooms/arena", "", http.StatusUnauthorized},
+		{"destroy with a wrong token", http.MethodDelete, "/rooms/arena", "secrets", http.StatusUnauthorized},
+		{"create with the token", http.MethodPost, "/rooms", "secret", http.StatusCreated},
+		{"list needs no token", http.MethodGet, "/rooms", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := request(t, srv, tt.method, tt.path, tt.token, ""); got != tt.want {
+				t.Errorf("status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomManagementDisabledWithoutToken(t *testing.T) {
+	srv := startServer(t, "")
+
+	for _, token := range []string{"", "anything"} {
+		if got := request(t, srv, http.MethodPost, "/rooms", token, ""); got != http.StatusForbidden {
+			t.Errorf("create with token %q: status %d, want %d", token, got, http.StatusForbidden)
+		}
+	}
+}
+
+func TestCreateRoomValidatesOverrides(t *testing.T) {
+	srv := startServer(t, "secret")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"allowed overrides", `{"id":"ok","config":{"tickRate":60,"maxRewind":"150ms"}}`, http.StatusCreated},
+		{"field outside the whitelist", `{"config":{"mapFile":"/etc/passwd"}}`, http.StatusBadRequest},
+		{"unknown request field", `{"id":"x","admin":true}`, http.StatusBadRequest},
+		{"tick rate out of range", `{"config":{"tickRate":1000}}`, http.StatusBadRequest},
+		{"more players than the server", `{"config":{"maxPlayers":100000}}`, http.StatusBadRequest},
+		{"snapshot rate above the tick rate", `{"config":{"tickRate":20,"snapshotRate":30}}`, http.StatusBadRequest},
+		{"rewind out of range", `{"config":{"maxRewind":"10s"}}`, http.StatusBadRequest},
+		{"negative move speed", `{"config":{"moveSpeed":-1}}`, http.StatusBadRequest},
+		{"malformed duration", `{"config":{"respawnDelay":"soon"}}`, http.StatusBadRequest},
+		{"existing room", `{"id":"ok"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := request(t, srv, http.MethodPost, "/rooms", "secret", tt.body); got != tt.want {
+				t.Errorf("status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,16 @@ package network
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"game-server-v1/pkg/game"
+	"game-server-v1/pkg/protocol"
+	"game-server-v1/pkg/transport"
 	"game-server-v1/pkg/types"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,25 +19,31 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	// Preferred first; clients that request neither get JSON
+	Subprotocols: []string{protocol.SubprotocolBinary, protocol.SubprotocolJSON},
 }
 
 func ReadPump(hub *game.GameHub, c *types.Client) {
+	// A kicked connection was unregistered by the kick and is closed by its
+	// WritePump after the close frame, or shortly after if that stalls
+	kicked := false
 	defer func() {
+		if kicked {
+			time.AfterFunc(types.WriteWait, func() { c.Conn.Close() })
+			return
+		}
 		// unregister the client when the loop exits
-		hub.GetUnregisterChan() <- c
+		hub.Unregister(c)
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
-	})
+	limiter := newRateLimiter(hub.GetConfig())
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		messageType, message, err := c.Conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var closeErr *transport.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code != types.CloseGoingAway && closeErr.Code != types.CloseNormal {
 				log.Printf("unexpected close from %s: %v", c.UUID, err)
 			} else {
 				log.Printf("read error from %s: %v", c.UUID, err)
@@ -46,10 +54,33 @@ func ReadPump(hub *game.GameHub, c *types.Client) {
 		// update last-seen timestamp
 		c.LastSeen = time.Now()
 
+		if messageType == transport.BinaryMessage {
+			allow, disconnect := enforceRateLimit(hub, c, limiter, binaryMessageType(message))
+			if disconnect {
+				kicked = true
+				break
+			}
+			if allow {
+				handleBinaryMessage(hub, c, message)
+			}
+			continue
+		}
+
 		// Try to parse message into a known type
 		var base types.BaseMessage
-		if err := json.Unmarshal(message, &base); err != nil {
-			log.Printf("invalid message from %s: %v", c.UUID, err)
+		parseErr := json.Unmarshal(message, &base)
+
+		// Malformed messages count against the default limit like unknown types
+		allow, disconnect := enforceRateLimit(hub, c, limiter, base.Type)
+		if disconnect {
+			kicked = true
+			break
+		}
+		if !allow {
+			continue
+		}
+		if parseErr != nil {
+			log.Printf("invalid message from %s: %v", c.UUID, parseErr)
 			continue
 		}
 
@@ -60,25 +91,212 @@ func ReadPump(hub *game.GameHub, c *types.Client) {
 				log.Printf("invalid player input from %s: %v", c.UUID, err)
 				continue
 			}
-			// Push into hub’s channel (central store will handle it in the game loop)
-			hub.GetPlayerInputChan() <- &input
+			// Inputs always apply to the connection's own player
+			input.PlayerID = c.UUID
+
+			// Queue for the game loop without blocking on a busy hub
+			hub.QueueInput(&input)
 
 		case "shoot":
-			var projMsg types.ProjectileMessage
-			if err := json.Unmarshal(message, &projMsg); err != nil {
-				log.Printf("invalid projectile message from %s: %v", c.UUID, err)
+			var shootMsg types.ShootMessage
+			if err := json.Unmarshal(message, &shootMsg); err != nil {
+				log.Printf("invalid shoot message from %s: %v", c.UUID, err)
+				continue
+			}
+			// Queue the shot; the game loop enforces cooldown and ammo
+			hub.Shoot(c, &shootMsg)
+
+		case "reload":
+			hub.Reload(c)
+
+		case "switchWeapon":
+			var switchMsg types.SwitchWeaponMessage
+			if err := json.Unmarshal(message, &switchMsg); err != nil {
+				log.Printf("invalid weapon switch from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.SwitchWeapon(c, switchMsg.Weapon)
+
+		case "snapshotAck":
+			var ack types.SnapshotAckMessage
+			if err := json.Unmarshal(message, &ack); err != nil {
+				log.Printf("invalid snapshot ack from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.AckSnapshot(c, ack.Tick)
+
+		case "pong":
+			var pong types.PongMessage
+			if err := json.Unmarshal(message, &pong); err != nil {
+				log.Printf("invalid pong from %s: %v", c.UUID, err)
 				continue
 			}
-			// Convert to Projectile and push into hub (you’d implement in hub/game state)
-			hub.AddProjectileFromClient(c, &projMsg)
+			hub.Pong(c, pong.ID, c.LastSeen)
+
+		case "timeSync":
+			var syncMsg types.TimeSyncMessage
+			if err := json.Unmarshal(message, &syncMsg); err != nil {
+				log.Printf("invalid time sync from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.TimeSync(c, &syncMsg)
+
+		case "eventAck":
+			var ack types.EventAckMessage
+			if err := json.Unmarshal(message, &ack); err != nil {
+				log.Printf("invalid event ack from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.AckEvents(c, ack.Seq)
+
+		case "chat":
+			var chat types.ChatMessage
+			if err := json.Unmarshal(message, &chat); err != nil {
+				log.Printf("invalid chat message from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.Chat(c, chat.Message)
+
+		case "snapshotRate":
+			var rateMsg types.SnapshotRateMessage
+			if err := json.Unmarshal(message, &rateMsg); err != nil {
+				log.Printf("invalid snapshot rate from %s: %v", c.UUID, err)
+				continue
+			}
+			hub.SetSnapshotRate(c, rateMsg.Rate)
+
+		case "hello":
+			log.Printf("ignoring repeated hello from %s", c.UUID)
 
 		default:
 			log.Printf("unrecognized message type %s from %s", base.Type, c.UUID)
+			hub.SendToClient(c, types.ErrorMessage{
+				Type:    string(types.ErrorMsg),
+				Code:    types.ErrorCodeBadRequest,
+				Message: fmt.Sprintf("unknown message type %q", base.Type),
+			})
+		}
+	}
+}
+
+// handleBinaryMessage dispatches a binary frame by its opcode
+func handleBinaryMessage(hub *game.GameHub, c *types.Client, frame []byte) {
+	if len(frame) == 0 {
+		return
+	}
+
+	switch frame[0] {
+	case protocol.OpPlayerInput:
+		input, err := protocol.DecodePlayerInput(frame)
+		if err != nil {
+			log.Printf("invalid binary player input from %s: %v", c.UUID, err)
+			return
+		}
+		// Inputs always apply to the connection's own player
+		input.PlayerID = c.UUID
+		hub.QueueInput(input)
+
+	case protocol.OpSnapshotAck:
+		tick, err := protocol.DecodeSnapshotAck(frame)
+		if err != nil {
+			log.Printf("invalid binary snapshot ack from %s: %v", c.UUID, err)
+			return
+		}
+		hub.AckSnapshot(c, tick)
+
+	default:
+		log.Printf("unrecognized binary opcode %d from %s", frame[0], c.UUID)
+	}
+}
+
+// binaryMessageType names a binary frame's message type for rate limiting
+func binaryMessageType(frame []byte) string {
+	if len(frame) > 0 {
+		switch frame[0] {
+		case protocol.OpPlayerInput:
+			return string(types.PlayerInputMsg)
+		case protocol.OpSnapshotAck:
+			return string(types.SnapshotAckMsg)
 		}
 	}
+	return types.DefaultRateLimitKey
 }
 
-// WritePump pumps messages from the hub to the websocket connection.
+// enforceRateLimit charges a message against the connection's limits. It reports whether
+// the message may be handled and whether the connection should be closed for flooding.
+func enforceRateLimit(hub *game.GameHub, c *types.Client, limiter *rateLimiter, msgType string) (allow, disconnect bool) {
+	switch limiter.check(msgType, time.Now()) {
+	case rateAllowed:
+		return true, false
+
+	case rateWarn:
+		log.Printf("client %s exceeded %s rate limit", c.UUID, msgType)
+		hub.SendToClient(c, types.ErrorMessage{
+			Type:    string(types.ErrorMsg),
+			Code:    types.ErrorCodeRateLimited,
+			Message: fmt.Sprintf("rate limit exceeded for %s, messages are being dropped", msgType),
+		})
+		return false, false
+
+	case rateDisconnect:
+		log.Printf("client %s kept flooding, disconnecting", c.UUID)
+		// The WritePump sends the close frame once the hub lets go of the client
+		hub.Kick(c, types.ClosePolicyViolation, "rate limit exceeded")
+		return false, true
+	}
+	return false, false
+}
+
+// writeFrame writes one message on its own, binary or text by content
+func writeFrame(client *types.Client, message []byte) error {
+	msgType := transport.TextMessage
+	if protocol.IsBinaryFrame(message) {
+		msgType = transport.BinaryMessage
+	}
+	return client.Conn.WriteMessage(msgType, message)
+}
+
+// writeSnapshot writes a snapshot, unreliably on datagram transports since the
+// next tick supersedes it. Snapshots too large for one unfragmented datagram go
+// reliably instead, as a lost fragment would lose the whole snapshot.
+func writeSnapshot(client *types.Client, snapshot []byte) error {
+	datagrams, ok := client.Conn.(transport.UnreliableWriter)
+	if !ok || len(snapshot) > transport.MaxUnreliableSize {
+		return writeFrame(client, snapshot)
+	}
+
+	msgType := transport.TextMessage
+	if protocol.IsBinaryFrame(snapshot) {
+		msgType = transport.BinaryMessage
+	}
+	return datagrams.WriteUnreliable(msgType, snapshot)
+}
+
+// writePing stamps a time sync ping with the moment it goes out. The stamp is recorded
+// before the write so a quick answer always finds it.
+func writePing(client *types.Client, ping types.PingMessage) error {
+	now := time.Now()
+	ping.ServerTime = types.ServerTime(now)
+	data, err := json.Marshal(ping)
+	if err != nil {
+		return err
+	}
+	client.MarkPingWritten(ping.ID, now)
+	return client.Conn.WriteMessage(transport.TextMessage, data)
+}
+
+// sendClose tells the peer the hub closed the connection, with the hub's code and reason if any
+func sendClose(client *types.Client) {
+	code := client.CloseCode
+	if code == 0 {
+		code = types.CloseNormal
+	}
+	client.Conn.SendClose(code, client.CloseReason)
+}
+
+// WritePump pumps messages from the hub to the client's connection, one frame
+// per message. Queued messages go out before the pending snapshot so events are
+// never overtaken by state that depends on them.
 //
 // A goroutine running WritePump is started for each connection. The
 // application ensures that there is at most one writer to a connection by
@@ -93,38 +311,45 @@ func WritePump(client *types.Client) {
 	for {
 		select {
 		case message, ok := <-client.Send:
-			client.Conn.SetWriteDeadline(time.Now().Add(types.WriteWait))
 			if !ok {
 				// The hub closed the channel.
-				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				sendClose(client)
 				return
 			}
-
-			w, err := client.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				log.Printf("Error getting writer for client %s: %v", client.UUID, err)
+			if err := writeFrame(client, message); err != nil {
+				log.Printf("Error writing message to client %s: %v", client.UUID, err)
 				return
 			}
+			continue
+		default:
+		}
 
-			// Write the message
-			w.Write(message)
+		select {
+		case message, ok := <-client.Send:
+			if !ok {
+				// The hub closed the channel.
+				sendClose(client)
+				return
+			}
+			if err := writeFrame(client, message); err != nil {
+				log.Printf("Error writing message to client %s: %v", client.UUID, err)
+				return
+			}
 
-			// Add queued messages to the current websocket message if any
-			n := len(client.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				additionalMessage := <-client.Send
-				w.Write(additionalMessage)
+		case snapshot := <-client.Snapshot:
+			if err := writeSnapshot(client, snapshot); err != nil {
+				log.Printf("Error writing snapshot to client %s: %v", client.UUID, err)
+				return
 			}
 
-			if err := w.Close(); err != nil {
-				log.Printf("Error closing writer for client %s: %v", client.UUID, err)
+		case ping := <-client.Ping:
+			if err := writePing(client, ping); err != nil {
+				log.Printf("Error writing ping to client %s: %v", client.UUID, err)
 				return
 			}
 
 		case <-ticker.C:
-			client.Conn.SetWriteDeadline(time.Now().Add(types.WriteWait))
-			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := client.Conn.Ping(); err != nil {
 				log.Printf("Ping failed for client %s: %v", client.UUID, err)
 				return
 			}
@@ -146,15 +371,12 @@ func WritePumpWithGameState(client *types.Client) {
 		case message, ok := <-client.Send:
 			if !ok {
 				// The hub closed the channel.
-				client.Conn.SetWriteDeadline(time.Now().Add(types.WriteWait))
-				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				sendClose(client)
 				return
 			}
 
-			client.Conn.SetWriteDeadline(time.Now().Add(types.WriteWait))
-
 			// Send the message (this could be a GameState update or any other message)
-			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := writeFrame(client, message); err != nil {
 				log.Printf("Error writing message to client %s: %v", client.UUID, err)
 				return
 			}
@@ -165,7 +387,7 @@ func WritePumpWithGameState(client *types.Client) {
 			for i := 0; i < n; i++ {
 				select {
 				case additionalMessage := <-client.Send:
-					if err := client.Conn.WriteMessage(websocket.TextMessage, additionalMessage); err != nil {
+					if err := writeFrame(client, additionalMessage); err != nil {
 						log.Printf("Error writing additional message to client %s: %v", client.UUID, err)
 						return
 					}
@@ -174,9 +396,20 @@ func WritePumpWithGameState(client *types.Client) {
 				}
 			}
 
+		case snapshot := <-client.Snapshot:
+			if err := writeSnapshot(client, snapshot); err != nil {
+				log.Printf("Error writing snapshot to client %s: %v", client.UUID, err)
+				return
+			}
+
+		case ping := <-client.Ping:
+			if err := writePing(client, ping); err != nil {
+				log.Printf("Error writing ping to client %s: %v", client.UUID, err)
+				return
+			}
+
 		case <-ticker.C:
-			client.Conn.SetWriteDeadline(time.Now().Add(types.WriteWait))
-			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := client.Conn.Ping(); err != nil {
 				log.Printf("Ping failed for client %s: %v", client.UUID, err)
 				return
 			}
@@ -198,47 +431,21 @@ func HandleClientMessage(hub *game.GameHub, c *types.Client, raw []byte) {
 			log.Printf("bad move message: %v", err)
 			return
 		}
-		game.UpdatePlayerMovement(hub, c, &moveMsg)
+		moveMsg.PlayerID = c.UUID
+		hub.QueueInput(&moveMsg)
 
-	case "projectile":
-		var projMsg types.ProjectileMessage
-		if err := json.Unmarshal(raw, &projMsg); err != nil {
-			log.Printf("bad projectile message: %v", err)
+	case "shoot":
+		var shootMsg types.ShootMessage
+		if err := json.Unmarshal(raw, &shootMsg); err != nil {
+			log.Printf("bad shoot message: %v", err)
 			return
 		}
-		hub.AddProjectileFromClient(c, &projMsg)
+		hub.Shoot(c, &shootMsg)
+
+	case "reload":
+		hub.Reload(c)
 
 	default:
 		log.Printf("unhandled message type: %s", base.Type)
 	}
 }
-
-func HandleSocket(hub *game.GameHub) {
-
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		ws, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Println("Upgrader error", err)
-			return
-		}
-
-		client := &types.Client{
-			UUID:     uuid.New().String(),
-			Conn:     ws,
-			Send:     make(chan []byte, 256),
-			LastSeen: time.Now(),
-		}
-
-		log.Println("New client connected", client.UUID)
-
-		hub.GetRegisterChan() <- client
-
-		//   here call go  client.readPump and write pump functions
-		ReadPump(hub, client)
-		WritePump(client)
-
-	})
-
-	fmt.Println("The game server is running at port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
-}
@@ -0,0 +1,45 @@
+package network
+
+import (
+	"game-server-v1/pkg/transport"
+	"game-server-v1/pkg/types"
+	"strings"
+	"testing"
+)
+
+// datagramConn is a pipe that also accepts unreliable writes, recording them
+type datagramConn struct {
+	transport.Conn
+	unreliable [][]byte
+}
+
+func (c *datagramConn) WriteUnreliable(msgType transport.MessageType, data []byte) error {
+	c.unreliable = append(c.unreliable, data)
+	return nil
+}
+
+func TestWriteSnapshotFallsBackToReliableWhenTooLarge(t *testing.T) {
+	server, peer := transport.Pipe()
+	defer peer.Close()
+	conn := &datagramConn{Conn: server}
+	client := &types.Client{Conn: conn}
+
+	small := []byte(`{"type":"gameState"}`)
+	if err := writeSnapshot(client, small); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.unreliable) != 1 {
+		t.Fatalf("small snapshot sent unreliably %d times, want once", len(conn.unreliable))
+	}
+
+	large := []byte(`{"type":"gameState","pad":"` + strings.Repeat("x", transport.MaxUnreliableSize) + `"}`)
+	if err := writeSnapshot(client, large); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.unreliable) != 1 {
+		t.Error("snapshot larger than a datagram sent unreliably")
+	}
+	if _, data, err := peer.ReadMessage(); err != nil || len(data) != len(large) {
+		t.Errorf("reliable read (%d bytes, %v), want the %d byte snapshot", len(data), err, len(large))
+	}
+}
@@ -0,0 +1,158 @@
+// Package protocol implements the compact binary wire encoding used for hot
+// messages. Every binary frame starts with a one-byte opcode; opcodes are below
+// 0x20 so a frame can never be mistaken for a JSON text message, which always
+// starts with '{'.
+//
+// Numbers are varints. Floats are quantised to fixed point and written as
+// zigzag varints. Entities are referenced by their numeric net ID instead of
+// their string ID.
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// WebSocket subprotocols offered by the server
+const (
+	SubprotocolJSON   = "game.json"
+	SubprotocolBinary = "game.bin"
+)
+
+// Opcodes of binary frames
+const (
+	OpPlayerInput    byte = 0x01 // client → server
+	OpGameState      byte = 0x02
+	OpGameStateDelta byte = 0x03
+	OpPlayerDamaged  byte = 0x04
+	OpPlayerKilled   byte = 0x05
+	OpSnapshotAck    byte = 0x06 // client → server
+)
+
+// Quantisation scales
+const (
+	PositionScale = 100.0 // 0.01 world units
+	InputScale    = 127.0 // movement axes in [-1, 1]
+)
+
+// Player flag bits
+const (
+	FlagFacingLeft byte = 1 << iota
+	FlagAlive
+	FlagInvulnerable
+	FlagReloading
+)
+
+var (
+	ErrShortFrame    = errors.New("protocol: frame truncated")
+	ErrUnknownOpcode = errors.New("protocol: unknown opcode")
+)
+
+// IsBinaryFrame reports whether data is a binary frame rather than JSON text
+func IsBinaryFrame(data []byte) bool {
+	return len(data) > 0 && data[0] < 0x20
+}
+
+// Writer builds a binary frame
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter starts a frame with the given opcode
+func NewWriter(op byte) *Writer {
+	return &Writer{buf: append(make([]byte, 0, 64), op)}
+}
+
+// Bytes returns the encoded frame
+func (w *Writer) Bytes() []byte { return w.buf }
+
+// Raw appends bytes that are already encoded, such as a fragment built by a zero Writer
+func (w *Writer) Raw(b []byte) { w.buf = append(w.buf, b...) }
+
+func (w *Writer) Byte(b byte) { w.buf = append(w.buf, b) }
+
+func (w *Writer) Uvarint(v uint64) { w.buf = binary.AppendUvarint(w.buf, v) }
+
+func (w *Writer) Varint(v int64) { w.buf = binary.AppendVarint(w.buf, v) }
+
+// Fixed writes a float quantised to 1/scale
+func (w *Writer) Fixed(v, scale float64) { w.Varint(int64(math.Round(v * scale))) }
+
+func (w *Writer) String(s string) {
+	w.Uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// Reader decodes a binary frame, remembering the first error
+type Reader struct {
+	buf []byte
+	err error
+}
+
+// NewReader reads the frame after its opcode byte
+func NewReader(frame []byte) *Reader {
+	if len(frame) == 0 {
+		return &Reader{err: ErrShortFrame}
+	}
+	return &Reader{buf: frame[1:]}
+}
+
+// Err returns the first decoding error
+func (r *Reader) Err() error { return r.err }
+
+func (r *Reader) Byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) == 0 {
+		r.err = ErrShortFrame
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *Reader) Uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = ErrShortFrame
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *Reader) Varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = ErrShortFrame
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+// Fixed reads a float quantised to 1/scale
+func (r *Reader) Fixed(scale float64) float64 { return float64(r.Varint()) / scale }
+
+func (r *Reader) String() string {
+	n := r.Uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if uint64(len(r.buf)) < n {
+		r.err = ErrShortFrame
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
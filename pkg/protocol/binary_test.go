@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"errors"
+	"game-server-v1/pkg/types"
+	"math"
+	"testing"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	uvarints := []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64}
+	varints := []int64{0, 1, -1, 63, -64, 64, -65, math.MinInt64, math.MaxInt64}
+	strs := []string{"", "a", "player-42", string(make([]byte, 300))}
+
+	w := NewWriter(OpGameState)
+	w.Byte(0xAB)
+	for _, v := range uvarints {
+		w.Uvarint(v)
+	}
+	for _, v := range varints {
+		w.Varint(v)
+	}
+	for _, s := range strs {
+		w.String(s)
+	}
+
+	frame := w.Bytes()
+	if frame[0] != OpGameState {
+		t.Fatalf("opcode = %#x, want %#x", frame[0], OpGameState)
+	}
+
+	r := NewReader(frame)
+	if got := r.Byte(); got != 0xAB {
+		t.Errorf("Byte() = %#x, want 0xab", got)
+	}
+	for _, want := range uvarints {
+		if got := r.Uvarint(); got != want {
+			t.Errorf("Uvarint() = %d, want %d", got, want)
+		}
+	}
+	for _, want := range varints {
+		if got := r.Varint(); got != want {
+			t.Errorf("Varint() = %d, want %d", got, want)
+		}
+	}
+	for _, want := range strs {
+		if got := r.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+}
+
+func TestFixedQuantisation(t *testing.T) {
+	values := []float64{0, 0.004, 0.005, -0.005, 1.23456, -1.23456, 49.999, -50, 1e6}
+	for _, scale := range []float64{PositionScale, InputScale} {
+		w := NewWriter(OpGameState)
+		for _, v := range values {
+			w.Fixed(v, scale)
+		}
+
+		r := NewReader(w.Bytes())
+		for _, v := range values {
+			got := r.Fixed(scale)
+			if math.Abs(got-v) > 0.5/scale+1e-12 {
+				t.Errorf("Fixed(%g) at scale %g decoded as %g", v, scale, got)
+			}
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("Err() = %v", err)
+		}
+	}
+}
+
+func TestReaderTruncatedFrame(t *testing.T) {
+	w := NewWriter(OpGameState)
+	w.Byte(1)
+	w.Uvarint(math.MaxUint64)
+	w.Varint(math.MinInt64)
+	w.String("hello")
+	frame := w.Bytes()
+
+	for n := 0; n < len(frame); n++ {
+		r := NewReader(frame[:n])
+		r.Byte()
+		r.Uvarint()
+		r.Varint()
+		_ = r.String()
+		if !errors.Is(r.Err(), ErrShortFrame) {
+			t.Errorf("frame cut to %d of %d bytes: Err() = %v, want ErrShortFrame", n, len(frame), r.Err())
+		}
+	}
+}
+
+func TestPlayerInputRoundTrip(t *testing.T) {
+	inputs := []types.PlayerInputMessage{
+		{SequenceID: 0, MoveX: 0, MoveY: 0},
+		{SequenceID: 1, MoveX: 1, MoveY: -1, FacingLeft: true, Timestamp: 12.345},
+		{SequenceID: 1 << 40, MoveX: -0.5, MoveY: 0.25, Timestamp: 86400.001},
+	}
+	for _, in := range inputs {
+		got, err := DecodePlayerInput(EncodePlayerInput(&in))
+		if err != nil {
+			t.Fatalf("DecodePlayerInput(%+v): %v", in, err)
+		}
+		if got.Type != string(types.PlayerInputMsg) {
+			t.Errorf("Type = %q, want %q", got.Type, types.PlayerInputMsg)
+		}
+		if got.SequenceID != in.SequenceID || got.FacingLeft != in.FacingLeft {
+			t.Errorf("decoded %+v, want %+v", got, in)
+		}
+		if math.Abs(got.MoveX-in.MoveX) > 0.5/InputScale || math.Abs(got.MoveY-in.MoveY) > 0.5/InputScale {
+			t.Errorf("movement decoded as (%g, %g), want (%g, %g)", got.MoveX, got.MoveY, in.MoveX, in.MoveY)
+		}
+		if math.Abs(got.Timestamp-in.Timestamp) > 0.001 {
+			t.Errorf("Timestamp decoded as %g, want %g", got.Timestamp, in.Timestamp)
+		}
+	}
+}
+
+func TestSnapshotAckRoundTrip(t *testing.T) {
+	for _, tick := range []uint64{0, 1, 1000, math.MaxUint64} {
+		got, err := DecodeSnapshotAck(EncodeSnapshotAck(tick))
+		if err != nil {
+			t.Fatalf("DecodeSnapshotAck(%d): %v", tick, err)
+		}
+		if got != tick {
+			t.Errorf("tick decoded as %d, want %d", got, tick)
+		}
+	}
+
+	if _, err := DecodeSnapshotAck([]byte{OpSnapshotAck}); !errors.Is(err, ErrShortFrame) {
+		t.Errorf("empty snapshotAck: err = %v, want ErrShortFrame", err)
+	}
+}
+
+func TestDecodeWrongOpcode(t *testing.T) {
+	input := &types.PlayerInputMessage{SequenceID: 1}
+	if _, err := DecodeSnapshotAck(EncodePlayerInput(input)); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("DecodeSnapshotAck(playerInput) err = %v, want ErrUnknownOpcode", err)
+	}
+	if _, err := DecodePlayerInput(EncodeSnapshotAck(1)); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("DecodePlayerInput(snapshotAck) err = %v, want ErrUnknownOpcode", err)
+	}
+	if _, err := DecodePlayerInput(nil); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("DecodePlayerInput(nil) err = %v, want ErrUnknownOpcode", err)
+	}
+}
+
+func TestIsBinaryFrame(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{nil, false},
+		{[]byte(`{"type":"playerInput"}`), false},
+		{EncodePlayerInput(&types.PlayerInputMessage{}), true},
+		{EncodeSnapshotAck(7), true},
+	}
+	for _, tt := range tests {
+		if got := IsBinaryFrame(tt.data); got != tt.want {
+			t.Errorf("IsBinaryFrame(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package protocol
+
+import "game-server-v1/pkg/types"
+
+// EncodePlayerInput writes a playerInput frame:
+// sequenceId uvarint | moveX fixed | moveY fixed | flags byte | timestamp uvarint (ms)
+func EncodePlayerInput(input *types.PlayerInputMessage) []byte {
+	w := NewWriter(OpPlayerInput)
+	w.Uvarint(uint64(input.SequenceID))
+	w.Fixed(input.MoveX, InputScale)
+	w.Fixed(input.MoveY, InputScale)
+
+	var flags byte
+	if input.FacingLeft {
+		flags |= FlagFacingLeft
+	}
+	w.Byte(flags)
+	w.Uvarint(uint64(input.Timestamp * 1000))
+	return w.Bytes()
+}
+
+// DecodePlayerInput reads a playerInput frame
+func DecodePlayerInput(frame []byte) (*types.PlayerInputMessage, error) {
+	if len(frame) == 0 || frame[0] != OpPlayerInput {
+		return nil, ErrUnknownOpcode
+	}
+
+	r := NewReader(frame)
+	input := &types.PlayerInputMessage{Type: string(types.PlayerInputMsg)}
+	input.SequenceID = int64(r.Uvarint())
+	input.MoveX = r.Fixed(InputScale)
+	input.MoveY = r.Fixed(InputScale)
+	input.FacingLeft = r.Byte()&FlagFacingLeft != 0
+	input.Timestamp = float64(r.Uvarint()) / 1000
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+// EncodeSnapshotAck writes a snapshotAck frame: tick uvarint
+func EncodeSnapshotAck(tick uint64) []byte {
+	w := NewWriter(OpSnapshotAck)
+	w.Uvarint(tick)
+	return w.Bytes()
+}
+
+// DecodeSnapshotAck reads a snapshotAck frame
+func DecodeSnapshotAck(frame []byte) (uint64, error) {
+	if len(frame) == 0 || frame[0] != OpSnapshotAck {
+		return 0, ErrUnknownOpcode
+	}
+
+	r := NewReader(frame)
+	tick := r.Uvarint()
+	return tick, r.Err()
+}
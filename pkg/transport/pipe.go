@@ -0,0 +1,104 @@
+package transport
+
+import "sync"
+
+// pipeBuffer is how many messages each direction of a pipe holds before writes block
+const pipeBuffer = 256
+
+type pipeMessage struct {
+	msgType MessageType
+	data    []byte
+}
+
+// pipe is the shared state of two connected in-memory ends
+type pipe struct {
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  *CloseError // set before done is closed if an end sent a close notice
+}
+
+// pipeConn is one end of an in-memory connection
+type pipeConn struct {
+	p    *pipe
+	in   chan pipeMessage
+	out  chan pipeMessage
+	name string
+}
+
+// Pipe returns two connected in-memory ends, for tests and in-process bots.
+// Messages written on one end are read in order on the other; closing either
+// end closes both once the messages already written have been read.
+func Pipe() (server, client Conn) {
+	p := &pipe{done: make(chan struct{})}
+	toServer := make(chan pipeMessage, pipeBuffer)
+	toClient := make(chan pipeMessage, pipeBuffer)
+
+	server = &pipeConn{p: p, in: toServer, out: toClient, name: "pipe:server"}
+	client = &pipeConn{p: p, in: toClient, out: toServer, name: "pipe:client"}
+	return server, client
+}
+
+func (c *pipeConn) ReadMessage() (MessageType, []byte, error) {
+	// Deliver everything written before the close
+	select {
+	case msg := <-c.in:
+		return msg.msgType, msg.data, nil
+	default:
+	}
+
+	select {
+	case msg := <-c.in:
+		return msg.msgType, msg.data, nil
+	case <-c.p.done:
+		if c.p.closeErr != nil {
+			return 0, nil, c.p.closeErr
+		}
+		return 0, nil, ErrClosed
+	}
+}
+
+func (c *pipeConn) WriteMessage(msgType MessageType, data []byte) error {
+	select {
+	case <-c.p.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.out <- pipeMessage{msgType: msgType, data: data}:
+		return nil
+	case <-c.p.done:
+		return ErrClosed
+	}
+}
+
+func (c *pipeConn) Ping() error {
+	select {
+	case <-c.p.done:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+func (c *pipeConn) SendClose(code int, reason string) error {
+	c.p.close(&CloseError{Code: code, Reason: truncateReason(reason)})
+	return nil
+}
+
+func (c *pipeConn) Close() error {
+	c.p.close(nil)
+	return nil
+}
+
+func (c *pipeConn) RemoteAddr() string {
+	return c.name
+}
+
+// close shuts both ends, recording the first close notice
+func (p *pipe) close(err *CloseError) {
+	p.closeOnce.Do(func() {
+		p.closeErr = err
+		close(p.done)
+	})
+}
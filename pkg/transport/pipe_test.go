@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPipeDeliversInOrderBothWays(t *testing.T) {
+	server, client := Pipe()
+	defer server.Close()
+
+	for i := 0; i < 10; i++ {
+		msgType := TextMessage
+		if i%2 == 1 {
+			msgType = BinaryMessage
+		}
+		if err := client.WriteMessage(msgType, fmt.Appendf(nil, "up-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.WriteMessage(msgType, fmt.Appendf(nil, "down-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		wantType := TextMessage
+		if i%2 == 1 {
+			wantType = BinaryMessage
+		}
+		if msgType, data, err := server.ReadMessage(); err != nil || msgType != wantType || string(data) != fmt.Sprintf("up-%d", i) {
+			t.Fatalf("server read (%d, %q, %v), want (%d, up-%d)", msgType, data, err, wantType, i)
+		}
+		if msgType, data, err := client.ReadMessage(); err != nil || msgType != wantType || string(data) != fmt.Sprintf("down-%d", i) {
+			t.Fatalf("client read (%d, %q, %v), want (%d, down-%d)", msgType, data, err, wantType, i)
+		}
+	}
+}
+
+func TestPipeSendCloseAfterQueuedMessages(t *testing.T) {
+	server, client := Pipe()
+
+	server.WriteMessage(TextMessage, []byte("last words"))
+	server.SendClose(1001, "going away")
+	server.Close()
+
+	// Messages written before the close are still delivered
+	if _, data, err := client.ReadMessage(); err != nil || string(data) != "last words" {
+		t.Fatalf("read (%q, %v), want the queued message", data, err)
+	}
+
+	var closeErr *CloseError
+	if _, _, err := client.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != 1001 || closeErr.Reason != "going away" {
+		t.Fatalf("read after close: %v, want close 1001 going away", err)
+	}
+	if err := client.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after close: %v, want ErrClosed", err)
+	}
+	if err := client.Ping(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ping after close: %v, want ErrClosed", err)
+	}
+}
+
+func TestPipeCloseUnblocksRead(t *testing.T) {
+	server, client := Pipe()
+
+	read := make(chan error, 1)
+	go func() {
+		_, _, err := server.ReadMessage()
+		read <- err
+	}()
+
+	client.Close()
+	select {
+	case err := <-read:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("pending read ended with %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not unblock a pending read on the other end")
+	}
+}
@@ -0,0 +1,537 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Packet kinds, the first byte of every UDP datagram
+const (
+	packetConnect    byte = 0xC1 // client → server handshake
+	packetAccept     byte = 0xC2 // server → client handshake reply
+	packetData       byte = 0xC3
+	packetDisconnect byte = 0xC4
+	packetChallenge  byte = 0xC5 // server → client, answers a connect without a valid cookie
+)
+
+// Data packet header flags
+const (
+	headerHasAck byte = 1 << iota // the ack fields are meaningful
+)
+
+// Message flags inside data packets
+const (
+	messageReliable byte = 1 << iota
+	messageBinary
+)
+
+// UDP tuning
+const (
+	udpMagic           = "GSV1"
+	dataHeaderSize     = 15    // kind, conn ID, seq, ack, ack bits, flags, message count
+	maxPacketSize      = 1200  // reliable messages are bundled up to a size that avoids fragmentation
+	maxDatagramSize    = 65507 // a single larger message cannot be sent at all
+	udpRecvBuffer      = 512   // delivered messages waiting for ReadMessage
+	maxReorder         = 128   // reliable messages held back waiting for an earlier one
+	maxPendingReliable = 1024  // unacknowledged reliable messages before the link is considered dead
+	resendInterval     = 100 * time.Millisecond
+	keepaliveInterval  = time.Second
+	udpIdleTimeout     = 10 * time.Second
+	udpTickInterval    = 50 * time.Millisecond
+	cookieSize         = 16
+	cookieLifetime     = 10 * time.Second // a challenge cookie is accepted for one to two lifetimes
+	minConnectSize     = 64               // connects are padded so a challenge is never larger
+)
+
+var (
+	// ErrTimeout is returned by ReadMessage when the peer stopped sending
+	ErrTimeout = errors.New("transport: peer timed out")
+
+	errReliableOverflow = errors.New("transport: too many unacknowledged reliable messages")
+	errMessageTooLarge  = errors.New("transport: message too large for a datagram")
+)
+
+// reliableMessage is a message resent until a packet carrying it is acknowledged
+type reliableMessage struct {
+	id       uint16
+	msgType  MessageType
+	data     []byte
+	lastSent time.Time // zero until first sent
+}
+
+// sentPacket remembers which reliable messages a packet carried
+type sentPacket struct {
+	seq      uint16
+	valid    bool
+	reliable []uint16
+}
+
+// udpConn is one end of a UDP connection. Every packet carries a sequence
+// number and acknowledges the last 33 packets received from the peer.
+// Unreliable messages are sent once; reliable ones are resent until a packet
+// carrying them is acknowledged, and delivered to the reader in order.
+type udpConn struct {
+	id      uint32
+	addr    *net.UDPAddr
+	socket  *net.UDPConn
+	dialed  bool   // the socket is connected to addr
+	onClose func() // releases listener or socket resources
+
+	recv      chan pipeMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error // set before done is closed
+
+	mu            sync.Mutex
+	localSeq      uint16
+	remoteSeq     uint16
+	remoteAckBits uint32
+	receivedAny   bool
+	sent          [256]sentPacket
+	pending       []*reliableMessage // ordered by id
+	nextSendID    uint16
+	nextRecvID    uint16
+	reorder       map[uint16]pipeMessage
+	lastSend      time.Time
+	lastRecv      time.Time
+}
+
+func newUDPConn(id uint32, socket *net.UDPConn, addr *net.UDPAddr, dialed bool, onClose func()) *udpConn {
+	now := time.Now()
+	return &udpConn{
+		id:       id,
+		addr:     addr,
+		socket:   socket,
+		dialed:   dialed,
+		onClose:  onClose,
+		recv:     make(chan pipeMessage, udpRecvBuffer),
+		done:     make(chan struct{}),
+		reorder:  make(map[uint16]pipeMessage),
+		lastSend: now,
+		lastRecv: now,
+	}
+}
+
+func (c *udpConn) ReadMessage() (MessageType, []byte, error) {
+	// Deliver everything received before the close
+	select {
+	case msg := <-c.recv:
+		return msg.msgType, msg.data, nil
+	default:
+	}
+
+	select {
+	case msg := <-c.recv:
+		return msg.msgType, msg.data, nil
+	case <-c.done:
+		return 0, nil, c.closeErr
+	}
+}
+
+// WriteMessage sends a message reliably and in order
+func (c *udpConn) WriteMessage(msgType MessageType, data []byte) error {
+	if len(data) > maxDatagramSize-dataHeaderSize-16 {
+		return errMessageTooLarge
+	}
+
+	c.mu.Lock()
+	if len(c.pending) >= maxPendingReliable {
+		c.mu.Unlock()
+		c.shutdown(errReliableOverflow)
+		return errReliableOverflow
+	}
+	c.pending = append(c.pending, &reliableMessage{id: c.nextSendID, msgType: msgType, data: data})
+	c.nextSendID++
+	c.mu.Unlock()
+
+	return c.flush(nil, false, time.Now())
+}
+
+// WriteUnreliable sends a message of up to MaxUnreliableSize once; it may be lost,
+// duplicated or reordered
+func (c *udpConn) WriteUnreliable(msgType MessageType, data []byte) error {
+	if len(data) > MaxUnreliableSize {
+		return errMessageTooLarge
+	}
+	return c.flush(&pipeMessage{msgType: msgType, data: data}, false, time.Now())
+}
+
+// Ping reports whether the connection is still up; keepalives are sent by tick
+func (c *udpConn) Ping() error {
+	select {
+	case <-c.done:
+		return c.closeErr
+	default:
+		return nil
+	}
+}
+
+// SendClose flushes queued reliable messages and tells the peer the connection is ending
+func (c *udpConn) SendClose(code int, reason string) error {
+	c.flush(nil, false, time.Now())
+
+	reason = truncateReason(reason)
+	pkt := make([]byte, 0, 16+len(reason))
+	pkt = append(pkt, packetDisconnect)
+	pkt = binary.BigEndian.AppendUint32(pkt, c.id)
+	pkt = binary.BigEndian.AppendUint16(pkt, uint16(code))
+	pkt = binary.AppendUvarint(pkt, uint64(len(reason)))
+	pkt = append(pkt, reason...)
+
+	// Disconnects are not acknowledged, so send a spare
+	c.write(pkt)
+	return c.write(pkt)
+}
+
+func (c *udpConn) Close() error {
+	c.shutdown(ErrClosed)
+	return nil
+}
+
+func (c *udpConn) RemoteAddr() string {
+	return "udp:" + c.addr.String()
+}
+
+// shutdown closes the connection once, recording why
+func (c *udpConn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.closeErr = err
+		close(c.done)
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+}
+
+// tick resends overdue reliable messages, keeps an idle link alive and detects a dead peer
+func (c *udpConn) tick(now time.Time) {
+	c.mu.Lock()
+	idle := now.Sub(c.lastRecv)
+	keepalive := now.Sub(c.lastSend) >= keepaliveInterval
+	c.mu.Unlock()
+
+	if idle > udpIdleTimeout {
+		c.shutdown(ErrTimeout)
+		return
+	}
+	c.flush(nil, keepalive, now)
+}
+
+// flush sends the given unreliable message, if any, together with every
+// reliable message that is unsent or overdue, in as many packets as needed
+func (c *udpConn) flush(extra *pipeMessage, keepalive bool, now time.Time) error {
+	select {
+	case <-c.done:
+		return c.closeErr
+	default:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var due []*reliableMessage
+	for _, m := range c.pending {
+		if m.lastSent.IsZero() || now.Sub(m.lastSent) >= resendInterval {
+			due = append(due, m)
+		}
+	}
+	if extra == nil && len(due) == 0 && !keepalive {
+		return nil
+	}
+
+	for {
+		seq := c.localSeq
+		c.localSeq++
+		pkt := c.appendHeader(make([]byte, 0, maxPacketSize), seq)
+
+		count := 0
+		var ids []uint16
+		if extra != nil {
+			pkt = appendMessage(pkt, 0, extra.msgType, 0, extra.data)
+			count++
+			extra = nil
+		}
+		for len(due) > 0 && count < 255 {
+			m := due[0]
+			if count > 0 && len(pkt)+len(m.data)+8 > maxPacketSize {
+				break
+			}
+			pkt = appendMessage(pkt, messageReliable, m.msgType, m.id, m.data)
+			m.lastSent = now
+			ids = append(ids, m.id)
+			count++
+			due = due[1:]
+		}
+		pkt[dataHeaderSize-1] = byte(count)
+
+		c.sent[seq%uint16(len(c.sent))] = sentPacket{seq: seq, valid: true, reliable: ids}
+		c.lastSend = now
+		if err := c.write(pkt); err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+	}
+}
+
+// appendHeader writes a data packet header with the current acks. Caller must hold c.mu.
+func (c *udpConn) appendHeader(pkt []byte, seq uint16) []byte {
+	var flags byte
+	if c.receivedAny {
+		flags |= headerHasAck
+	}
+
+	pkt = append(pkt, packetData)
+	pkt = binary.BigEndian.AppendUint32(pkt, c.id)
+	pkt = binary.BigEndian.AppendUint16(pkt, seq)
+	pkt = binary.BigEndian.AppendUint16(pkt, c.remoteSeq)
+	pkt = binary.BigEndian.AppendUint32(pkt, c.remoteAckBits)
+	pkt = append(pkt, flags)
+	return append(pkt, 0) // message count, filled in by flush
+}
+
+func appendMessage(pkt []byte, flags byte, msgType MessageType, id uint16, data []byte) []byte {
+	if msgType == BinaryMessage {
+		flags |= messageBinary
+	}
+	pkt = append(pkt, flags)
+	if flags&messageReliable != 0 {
+		pkt = binary.BigEndian.AppendUint16(pkt, id)
+	}
+	pkt = binary.AppendUvarint(pkt, uint64(len(data)))
+	return append(pkt, data...)
+}
+
+func (c *udpConn) write(pkt []byte) error {
+	var err error
+	if c.dialed {
+		_, err = c.socket.Write(pkt)
+	} else {
+		_, err = c.socket.WriteToUDP(pkt, c.addr)
+	}
+	return err
+}
+
+// receive processes one data packet from the peer
+func (c *udpConn) receive(pkt []byte, now time.Time) error {
+	if len(pkt) < dataHeaderSize {
+		return fmt.Errorf("transport: short packet (%d bytes)", len(pkt))
+	}
+	seq := binary.BigEndian.Uint16(pkt[5:])
+	ack := binary.BigEndian.Uint16(pkt[7:])
+	ackBits := binary.BigEndian.Uint32(pkt[9:])
+	flags := pkt[13]
+	count := int(pkt[14])
+	body := pkt[dataHeaderSize:]
+
+	msgs, err := parseMessages(body, count)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Acknowledging a packet means keeping its reliables, so one that does not fit
+	// is dropped unacknowledged and its reliables are resent
+	if !c.fits(msgs) {
+		return nil
+	}
+
+	c.lastRecv = now
+	fresh := c.recordRemote(seq)
+	if flags&headerHasAck != 0 {
+		c.processAcks(ack, ackBits)
+	}
+	if !fresh {
+		return nil
+	}
+
+	for _, m := range msgs {
+		if !m.reliable {
+			c.recv <- m.msg
+			continue
+		}
+		c.receiveReliable(m.id, m.msg)
+	}
+	return nil
+}
+
+// receivedMessage is one message parsed from a data packet
+type receivedMessage struct {
+	reliable bool
+	id       uint16
+	msg      pipeMessage
+}
+
+// parseMessages splits a data packet body into its messages
+func parseMessages(body []byte, count int) ([]receivedMessage, error) {
+	msgs := make([]receivedMessage, 0, count)
+	for i := 0; i < count; i++ {
+		if len(body) < 1 {
+			return nil, fmt.Errorf("transport: truncated message")
+		}
+		msgFlags := body[0]
+		body = body[1:]
+
+		var m receivedMessage
+		if msgFlags&messageReliable != 0 {
+			if len(body) < 2 {
+				return nil, fmt.Errorf("transport: truncated message")
+			}
+			m.reliable = true
+			m.id = binary.BigEndian.Uint16(body)
+			body = body[2:]
+		}
+
+		size, n := binary.Uvarint(body)
+		if n <= 0 || uint64(len(body)-n) < size {
+			return nil, fmt.Errorf("transport: truncated message")
+		}
+		m.msg = pipeMessage{msgType: TextMessage, data: body[n : n+int(size)]}
+		if msgFlags&messageBinary != 0 {
+			m.msg.msgType = BinaryMessage
+		}
+		body = body[n+int(size):]
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// fits reports whether a packet's messages can be taken in: everything they make
+// deliverable must fit the receive buffer, and reliables still waiting for an
+// earlier one must fit the reorder buffer. Caller must hold c.mu.
+func (c *udpConn) fits(msgs []receivedMessage) bool {
+	deliver := 0
+	var held map[uint16]bool
+	for _, m := range msgs {
+		if !m.reliable {
+			deliver++
+			continue
+		}
+		if diff := int16(m.id - c.nextRecvID); diff < 0 || diff >= maxPendingReliable {
+			continue
+		}
+		if held == nil {
+			held = make(map[uint16]bool, len(c.reorder)+len(msgs))
+			for id := range c.reorder {
+				held[id] = true
+			}
+		}
+		held[m.id] = true
+	}
+	if held == nil {
+		return deliver <= cap(c.recv)-len(c.recv)
+	}
+
+	for next := c.nextRecvID; held[next]; next++ {
+		delete(held, next)
+		deliver++
+	}
+	return deliver <= cap(c.recv)-len(c.recv) && len(held) <= maxReorder
+}
+
+// recordRemote folds a received sequence number into the ack state. It reports
+// false for duplicates and packets too old to track. Caller must hold c.mu.
+func (c *udpConn) recordRemote(seq uint16) bool {
+	if !c.receivedAny {
+		c.receivedAny = true
+		c.remoteSeq = seq
+		return true
+	}
+
+	diff := int16(seq - c.remoteSeq)
+	switch {
+	case diff > 0:
+		shift := uint(diff)
+		c.remoteAckBits <<= shift
+		if shift <= 32 {
+			c.remoteAckBits |= 1 << (shift - 1) // the previous newest
+		}
+		c.remoteSeq = seq
+		return true
+	case diff == 0:
+		return false
+	default:
+		back := uint(-diff)
+		if back > 32 {
+			return false
+		}
+		mask := uint32(1) << (back - 1)
+		if c.remoteAckBits&mask != 0 {
+			return false
+		}
+		c.remoteAckBits |= mask
+		return true
+	}
+}
+
+// processAcks retires reliable messages carried by acknowledged packets. Caller must hold c.mu.
+func (c *udpConn) processAcks(ack uint16, ackBits uint32) {
+	c.acknowledge(ack)
+	for i := uint16(0); i < 32; i++ {
+		if ackBits&(1<<i) != 0 {
+			c.acknowledge(ack - 1 - i)
+		}
+	}
+}
+
+func (c *udpConn) acknowledge(seq uint16) {
+	slot := &c.sent[seq%uint16(len(c.sent))]
+	if !slot.valid || slot.seq != seq {
+		return
+	}
+	slot.valid = false
+
+	for _, id := range slot.reliable {
+		for i, m := range c.pending {
+			if m.id == id {
+				c.pending = append(c.pending[:i], c.pending[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// receiveReliable delivers reliable messages in order, holding early ones back. Caller must hold c.mu.
+func (c *udpConn) receiveReliable(id uint16, msg pipeMessage) {
+	diff := int16(id - c.nextRecvID)
+	if diff < 0 || diff >= maxPendingReliable {
+		return // duplicate of a delivered message, or nonsense
+	}
+	if diff > 0 {
+		// receive made sure there is room
+		c.reorder[id] = msg
+		return
+	}
+
+	c.recv <- msg
+	c.nextRecvID++
+	for {
+		next, ok := c.reorder[c.nextRecvID]
+		if !ok {
+			return
+		}
+		delete(c.reorder, c.nextRecvID)
+		c.recv <- next
+		c.nextRecvID++
+	}
+}
+
+// receiveDisconnect closes the connection with the peer's code and reason
+func (c *udpConn) receiveDisconnect(pkt []byte) {
+	if len(pkt) < 7 {
+		return
+	}
+	code := binary.BigEndian.Uint16(pkt[5:])
+	reason := ""
+	if size, n := binary.Uvarint(pkt[7:]); n > 0 && uint64(len(pkt)-7-n) >= size {
+		reason = string(pkt[7+n : 7+n+int(size)])
+	}
+	c.shutdown(&CloseError{Code: int(code), Reason: reason})
+}
@@ -0,0 +1,232 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// lossyLink connects two udpConns over localhost sockets, moving packets between
+// them by hand so a test can drop, duplicate and reorder them and drive the clock
+type lossyLink struct {
+	t      *testing.T
+	a, b   *udpConn
+	sa, sb *net.UDPConn
+	now    time.Time
+	rng    *rand.Rand
+
+	drop, duplicate float64 // chance a packet is lost or delivered twice
+}
+
+func newLossyLink(t *testing.T, drop, duplicate float64) *lossyLink {
+	t.Helper()
+	sa, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		sa.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sa.Close()
+		sb.Close()
+	})
+
+	return &lossyLink{
+		t:         t,
+		a:         newUDPConn(1, sa, sb.LocalAddr().(*net.UDPAddr), false, nil),
+		b:         newUDPConn(1, sb, sa.LocalAddr().(*net.UDPAddr), false, nil),
+		sa:        sa,
+		sb:        sb,
+		now:       time.Now(),
+		rng:       rand.New(rand.NewPCG(1, 2)),
+		drop:      drop,
+		duplicate: duplicate,
+	}
+}
+
+// round advances the clock by one resend interval, lets a resend what is overdue and
+// b acknowledge what it got, passing each side's packets through the lossy link
+func (l *lossyLink) round() {
+	l.t.Helper()
+	l.now = l.now.Add(resendInterval)
+
+	if err := l.a.flush(nil, false, l.now); err != nil {
+		l.t.Fatalf("a.flush: %v", err)
+	}
+	l.deliver(l.sb, l.b)
+
+	if err := l.b.flush(nil, true, l.now); err != nil {
+		l.t.Fatalf("b.flush: %v", err)
+	}
+	l.deliver(l.sa, l.a)
+}
+
+// deliver reads every datagram waiting on socket and feeds them to c shuffled,
+// dropping and duplicating some
+func (l *lossyLink) deliver(socket *net.UDPConn, c *udpConn) {
+	l.t.Helper()
+
+	var pkts [][]byte
+	buf := make([]byte, maxDatagramSize)
+	for {
+		socket.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+		n, err := socket.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			l.t.Fatalf("read: %v", err)
+		}
+
+		pkt := append([]byte(nil), buf[:n]...)
+		if l.rng.Float64() < l.drop {
+			continue
+		}
+		pkts = append(pkts, pkt)
+		if l.rng.Float64() < l.duplicate {
+			pkts = append(pkts, pkt)
+		}
+	}
+
+	l.rng.Shuffle(len(pkts), func(i, j int) { pkts[i], pkts[j] = pkts[j], pkts[i] })
+	for _, pkt := range pkts {
+		if err := c.receive(pkt, l.now); err != nil {
+			l.t.Fatalf("receive: %v", err)
+		}
+	}
+}
+
+// received takes every message b has delivered so far
+func (l *lossyLink) received() []string {
+	var msgs []string
+	for {
+		select {
+		case m := <-l.b.recv:
+			msgs = append(msgs, string(m.data))
+		default:
+			return msgs
+		}
+	}
+}
+
+// pending returns how many reliable messages a still waits to have acknowledged
+func (l *lossyLink) pending() int {
+	l.a.mu.Lock()
+	defer l.a.mu.Unlock()
+	return len(l.a.pending)
+}
+
+// checkInOrder fails unless got is exactly msg-0 through msg-(n-1)
+func checkInOrder(t *testing.T, got []string, n int) {
+	t.Helper()
+	if len(got) != n {
+		t.Fatalf("delivered %d messages, want %d", len(got), n)
+	}
+	for i, m := range got {
+		if want := fmt.Sprintf("msg-%d", i); m != want {
+			t.Fatalf("message %d = %q, want %q", i, m, want)
+		}
+	}
+}
+
+func TestReliableDeliveryUnderLossAndReordering(t *testing.T) {
+	const n = 500
+	l := newLossyLink(t, 0.3, 0.1)
+
+	var got []string
+	sent := 0
+	for rounds := 0; len(got) < n || l.pending() > 0; rounds++ {
+		if rounds > 500 {
+			t.Fatalf("after %d rounds: delivered %d of %d, %d unacknowledged", rounds, len(got), n, l.pending())
+		}
+
+		// Keep writing while earlier messages are still being resent
+		for i := 0; i < 20 && sent < n; i++ {
+			if err := l.a.WriteMessage(TextMessage, fmt.Appendf(nil, "msg-%d", sent)); err != nil {
+				t.Fatalf("WriteMessage: %v", err)
+			}
+			sent++
+		}
+
+		l.round()
+		got = append(got, l.received()...)
+	}
+
+	checkInOrder(t, got, n)
+	if len(l.b.reorder) != 0 {
+		t.Errorf("%d messages left in the reorder buffer", len(l.b.reorder))
+	}
+}
+
+func TestReliableReceiveBufferBackpressure(t *testing.T) {
+	const n = 3 * udpRecvBuffer / 2
+	l := newLossyLink(t, 0, 0)
+
+	for i := 0; i < n; i++ {
+		if err := l.a.WriteMessage(TextMessage, fmt.Appendf(nil, "msg-%d", i)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	// Nobody reads: packets that don't fit must be left unacknowledged, not block receive.
+	// Packets are taken whole, so the buffer stops short of full.
+	for i := 0; i < 5; i++ {
+		l.round()
+	}
+	if len(l.b.recv) < cap(l.b.recv)/2 {
+		t.Fatalf("receive buffer holds only %d of %d", len(l.b.recv), cap(l.b.recv))
+	}
+	if l.pending() == 0 {
+		t.Fatal("messages that did not fit the receive buffer were acknowledged")
+	}
+
+	var got []string
+	for rounds := 0; len(got) < n || l.pending() > 0; rounds++ {
+		if rounds > 50 {
+			t.Fatalf("after %d rounds: delivered %d of %d, %d unacknowledged", rounds, len(got), n, l.pending())
+		}
+		got = append(got, l.received()...)
+		l.round()
+	}
+	checkInOrder(t, got, n)
+}
+
+func TestWriteUnreliableFitsOnePacket(t *testing.T) {
+	l := newLossyLink(t, 0, 0)
+
+	if err := l.a.WriteUnreliable(BinaryMessage, make([]byte, MaxUnreliableSize+1)); !errors.Is(err, errMessageTooLarge) {
+		t.Fatalf("oversized unreliable write: %v, want errMessageTooLarge", err)
+	}
+	if err := l.a.WriteUnreliable(BinaryMessage, make([]byte, MaxUnreliableSize)); err != nil {
+		t.Fatalf("largest unreliable write: %v", err)
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	l.sb.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := l.sb.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := append([]byte(nil), buf[:n]...)
+	if n > maxPacketSize {
+		t.Errorf("datagram of %d bytes, want at most %d", n, maxPacketSize)
+	}
+	l.sb.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := l.sb.Read(buf); err == nil {
+		t.Error("the oversized message was sent anyway")
+	}
+
+	if err := l.b.receive(pkt, l.now); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-l.b.recv; len(msg.data) != MaxUnreliableSize {
+		t.Errorf("received %d bytes, want %d", len(msg.data), MaxUnreliableSize)
+	}
+}
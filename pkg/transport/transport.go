@@ -0,0 +1,83 @@
+// Package transport abstracts the connection a client talks to the server over,
+// so the game loop and pumps work the same on WebSockets, UDP or in memory.
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// MessageType distinguishes text (JSON) from binary messages; values match WebSocket opcodes
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// ErrClosed is returned by operations on a connection that has been closed
+var ErrClosed = errors.New("transport: connection closed")
+
+// CloseError is returned by ReadMessage when the peer closed the connection with a code and reason
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("transport: closed by peer (%d %s)", e.Code, e.Reason)
+}
+
+// MaxCloseReason is the longest close reason in bytes; a WebSocket close frame
+// carries at most 125 bytes, two of them the code
+const MaxCloseReason = 123
+
+// truncateReason shortens a close reason to MaxCloseReason bytes without splitting a character
+func truncateReason(reason string) string {
+	if len(reason) <= MaxCloseReason {
+		return reason
+	}
+	cut := MaxCloseReason
+	for cut > 0 && !utf8.RuneStart(reason[cut]) {
+		cut--
+	}
+	return reason[:cut]
+}
+
+// Conn is a message-oriented connection to one client.
+//
+// ReadMessage may only be called from one goroutine and WriteMessage and Ping
+// from one other goroutine. SendClose and Close are safe to call from anywhere.
+type Conn interface {
+	// ReadMessage blocks until the next message arrives
+	ReadMessage() (MessageType, []byte, error)
+
+	// WriteMessage sends one message
+	WriteMessage(msgType MessageType, data []byte) error
+
+	// Ping checks the peer is still there; transports without keepalives return nil
+	Ping() error
+
+	// SendClose tells the peer the connection is ending and why; Close must still be called.
+	// Reasons longer than MaxCloseReason are truncated.
+	SendClose(code int, reason string) error
+
+	// Close releases the connection, unblocking any pending read
+	Close() error
+
+	// RemoteAddr describes the peer for logging
+	RemoteAddr() string
+}
+
+// MaxUnreliableSize is the largest message WriteUnreliable accepts, so that it fits
+// one datagram small enough to avoid IP fragmentation. Larger messages have to go
+// through WriteMessage.
+const MaxUnreliableSize = maxPacketSize - dataHeaderSize - 8
+
+// UnreliableWriter is implemented by datagram transports, whose WriteMessage is
+// reliable and ordered but which can also send a message that may be lost or
+// reordered, for state the next tick supersedes anyway
+type UnreliableWriter interface {
+	WriteUnreliable(msgType MessageType, data []byte) error
+}
@@ -0,0 +1,423 @@
+package transport
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnectRequest is what a UDP client asks for in its handshake
+type ConnectRequest struct {
+	Binary      bool   // use the binary encoding instead of JSON
+	ResumeToken string // reclaim a player after a dropped connection
+	Room        string // room to join; empty for the default room
+}
+
+// Connect request flags
+const (
+	connectBinary byte = 1 << iota
+	connectCookie      // a challenge cookie follows the flags
+)
+
+// accepted is a handshake waiting for Accept
+type accepted struct {
+	conn *udpConn
+	req  ConnectRequest
+}
+
+// UDPListener accepts UDP clients on one socket and demultiplexes their packets by connection ID.
+// A connection is only created once the client has echoed a challenge cookie, which
+// proves it receives at its address; spoofed connects cost the listener no state.
+type UDPListener struct {
+	socket   *net.UDPConn
+	accepted chan accepted
+	done     chan struct{}
+	once     sync.Once
+	secret   []byte // keys the challenge cookies
+
+	mu sync.Mutex
+	// conns by connection ID, and by client address and nonce so a repeated
+	// connect request is answered with the same connection
+	conns      map[uint32]*udpConn
+	handshakes map[string]*udpConn
+}
+
+// ListenUDP starts accepting UDP clients on addr
+func ListenUDP(addr string) (*UDPListener, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	socket, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &UDPListener{
+		socket:     socket,
+		secret:     make([]byte, 32),
+		accepted:   make(chan accepted, 64),
+		done:       make(chan struct{}),
+		conns:      make(map[uint32]*udpConn),
+		handshakes: make(map[string]*udpConn),
+	}
+	crand.Read(l.secret)
+	go l.readLoop()
+	go l.tickLoop()
+	return l, nil
+}
+
+// Accept waits for the next client to complete its handshake
+func (l *UDPListener) Accept() (Conn, ConnectRequest, error) {
+	select {
+	case a := <-l.accepted:
+		return a.conn, a.req, nil
+	case <-l.done:
+		return nil, ConnectRequest{}, ErrClosed
+	}
+}
+
+// Addr returns the listening address
+func (l *UDPListener) Addr() net.Addr {
+	return l.socket.LocalAddr()
+}
+
+// Close stops the listener and closes every connection on it
+func (l *UDPListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.socket.Close()
+
+		l.mu.Lock()
+		conns := make([]*udpConn, 0, len(l.conns))
+		for _, c := range l.conns {
+			conns = append(conns, c)
+		}
+		l.mu.Unlock()
+
+		for _, c := range conns {
+			c.shutdown(ErrClosed)
+		}
+	})
+	return err
+}
+
+func (l *UDPListener) readLoop() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.socket.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if n < 5 {
+			continue
+		}
+
+		// Messages are handed to readers as slices of the packet
+		pkt := append([]byte(nil), buf[:n]...)
+
+		if pkt[0] == packetConnect {
+			l.handleConnect(pkt, addr)
+			continue
+		}
+
+		l.mu.Lock()
+		c, ok := l.conns[binary.BigEndian.Uint32(pkt[1:])]
+		l.mu.Unlock()
+
+		// Ignore packets claiming a connection from a different address
+		if !ok || c.addr.AddrPort() != addr.AddrPort() {
+			continue
+		}
+
+		switch pkt[0] {
+		case packetData:
+			if err := c.receive(pkt, time.Now()); err != nil {
+				c.shutdown(err)
+			}
+		case packetDisconnect:
+			c.receiveDisconnect(pkt)
+		}
+	}
+}
+
+// handleConnect answers a connect request, creating the connection on first sight.
+// A request without a valid cookie is only answered with a challenge.
+func (l *UDPListener) handleConnect(pkt []byte, addr *net.UDPAddr) {
+	nonce, cookie, req, err := parseConnect(pkt)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if !l.validCookie(cookie, addr, nonce, now) {
+		// Padding keeps the challenge from amplifying spoofed traffic
+		if len(pkt) < minConnectSize {
+			return
+		}
+		reply := []byte{packetChallenge}
+		reply = binary.BigEndian.AppendUint64(reply, nonce)
+		reply = append(reply, l.cookie(addr, nonce, cookieSlot(now))...)
+		l.socket.WriteToUDP(reply, addr)
+		return
+	}
+
+	key := fmt.Sprintf("%s/%d", addr, nonce)
+
+	l.mu.Lock()
+	c, ok := l.handshakes[key]
+	if !ok {
+		id := l.newConnID()
+		c = newUDPConn(id, l.socket, addr, false, func() {
+			l.mu.Lock()
+			delete(l.conns, id)
+			delete(l.handshakes, key)
+			l.mu.Unlock()
+		})
+		l.conns[id] = c
+		l.handshakes[key] = c
+	}
+	l.mu.Unlock()
+
+	if !ok {
+		select {
+		case l.accepted <- accepted{conn: c, req: req}:
+		default:
+			// Nobody is accepting; the client will retry
+			c.shutdown(ErrClosed)
+			return
+		}
+	}
+
+	reply := []byte{packetAccept}
+	reply = binary.BigEndian.AppendUint64(reply, nonce)
+	reply = binary.BigEndian.AppendUint32(reply, c.id)
+	l.socket.WriteToUDP(reply, addr)
+}
+
+// cookie derives the challenge cookie for a client address and nonce in a time slot
+func (l *UDPListener) cookie(addr *net.UDPAddr, nonce uint64, slot int64) []byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(addr.String()))
+	mac.Write(binary.BigEndian.AppendUint64(nil, nonce))
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(slot)))
+	return mac.Sum(nil)[:cookieSize]
+}
+
+// validCookie reports whether a connect echoed a cookie issued to its address and
+// nonce in the current or previous time slot
+func (l *UDPListener) validCookie(cookie []byte, addr *net.UDPAddr, nonce uint64, now time.Time) bool {
+	if len(cookie) != cookieSize {
+		return false
+	}
+	slot := cookieSlot(now)
+	return hmac.Equal(cookie, l.cookie(addr, nonce, slot)) || hmac.Equal(cookie, l.cookie(addr, nonce, slot-1))
+}
+
+// cookieSlot numbers the cookieLifetime periods challenge cookies are issued in
+func cookieSlot(now time.Time) int64 {
+	return now.UnixNano() / int64(cookieLifetime)
+}
+
+// newConnID picks an unused, non-zero connection ID. Caller must hold l.mu.
+func (l *UDPListener) newConnID() uint32 {
+	for {
+		id := rand.Uint32()
+		if _, taken := l.conns[id]; id != 0 && !taken {
+			return id
+		}
+	}
+}
+
+// tickLoop drives resends, keepalives and timeouts for every connection
+func (l *UDPListener) tickLoop() {
+	ticker := time.NewTicker(udpTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			conns := make([]*udpConn, 0, len(l.conns))
+			for _, c := range l.conns {
+				conns = append(conns, c)
+			}
+			l.mu.Unlock()
+
+			for _, c := range conns {
+				c.tick(now)
+			}
+		}
+	}
+}
+
+// DialUDP connects to a UDP listener, answering its challenge and repeating the
+// handshake until it is accepted or timeout passes. It is used by native test
+// clients and bots.
+func DialUDP(addr string, req ConnectRequest, timeout time.Duration) (Conn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	socket, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := rand.Uint64()
+	connect := appendConnect(nil, nonce, nil, req)
+
+	buf := make([]byte, maxDatagramSize)
+	deadline := time.Now().Add(timeout)
+	var id uint32
+	for id == 0 {
+		if time.Now().After(deadline) {
+			socket.Close()
+			return nil, ErrTimeout
+		}
+		socket.Write(connect)
+
+		socket.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+		n, err := socket.Read(buf)
+		if err != nil || n < 9 || binary.BigEndian.Uint64(buf[1:]) != nonce {
+			continue
+		}
+		switch {
+		case n == 13 && buf[0] == packetAccept:
+			id = binary.BigEndian.Uint32(buf[9:])
+		case n == 9+cookieSize && buf[0] == packetChallenge:
+			// Connect again right away, echoing the cookie
+			connect = appendConnect(nil, nonce, buf[9:n], req)
+		}
+	}
+	socket.SetReadDeadline(time.Time{})
+
+	c := newUDPConn(id, socket, udpAddr, true, func() { socket.Close() })
+	go dialReadLoop(c, socket)
+	go dialTickLoop(c)
+	return c, nil
+}
+
+func dialReadLoop(c *udpConn, socket *net.UDPConn) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, err := socket.Read(buf)
+		if err != nil {
+			c.shutdown(ErrClosed)
+			return
+		}
+		if n < 5 || binary.BigEndian.Uint32(buf[1:]) != c.id {
+			continue
+		}
+		pkt := append([]byte(nil), buf[:n]...)
+
+		switch pkt[0] {
+		case packetData:
+			if err := c.receive(pkt, time.Now()); err != nil {
+				c.shutdown(err)
+			}
+		case packetDisconnect:
+			c.receiveDisconnect(pkt)
+		}
+	}
+}
+
+func dialTickLoop(c *udpConn) {
+	ticker := time.NewTicker(udpTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.tick(now)
+		}
+	}
+}
+
+// appendConnect encodes a connect request: magic, nonce, flags, the challenge cookie
+// if any, resume token and room, zero-padded to minConnectSize. The room is omitted
+// when empty, as older clients do; the padding then reads as an empty room.
+func appendConnect(pkt []byte, nonce uint64, cookie []byte, req ConnectRequest) []byte {
+	var flags byte
+	if req.Binary {
+		flags |= connectBinary
+	}
+	if cookie != nil {
+		flags |= connectCookie
+	}
+
+	start := len(pkt)
+	pkt = append(pkt, packetConnect)
+	pkt = append(pkt, udpMagic...)
+	pkt = binary.BigEndian.AppendUint64(pkt, nonce)
+	pkt = append(pkt, flags)
+	pkt = append(pkt, cookie...)
+	pkt = binary.AppendUvarint(pkt, uint64(len(req.ResumeToken)))
+	pkt = append(pkt, req.ResumeToken...)
+	if req.Room != "" {
+		pkt = binary.AppendUvarint(pkt, uint64(len(req.Room)))
+		pkt = append(pkt, req.Room...)
+	}
+	for len(pkt)-start < minConnectSize {
+		pkt = append(pkt, 0)
+	}
+	return pkt
+}
+
+// parseConnect decodes a connect request, returning its nonce and cookie, nil if none
+func parseConnect(pkt []byte) (uint64, []byte, ConnectRequest, error) {
+	errBad := errors.New("transport: bad connect packet")
+
+	const fixed = 1 + len(udpMagic) + 8 + 1
+	if len(pkt) < fixed || string(pkt[1:1+len(udpMagic)]) != udpMagic {
+		return 0, nil, ConnectRequest{}, errBad
+	}
+	nonce := binary.BigEndian.Uint64(pkt[1+len(udpMagic):])
+	flags := pkt[fixed-1]
+	req := ConnectRequest{Binary: flags&connectBinary != 0}
+
+	rest := pkt[fixed:]
+	var cookie []byte
+	if flags&connectCookie != 0 {
+		if len(rest) < cookieSize {
+			return 0, nil, ConnectRequest{}, errBad
+		}
+		cookie, rest = rest[:cookieSize], rest[cookieSize:]
+	}
+
+	token, rest, ok := readString(rest)
+	if !ok {
+		return 0, nil, ConnectRequest{}, errBad
+	}
+	req.ResumeToken = token
+	if len(rest) > 0 {
+		if req.Room, _, ok = readString(rest); !ok {
+			return 0, nil, ConnectRequest{}, errBad
+		}
+	}
+	return nonce, cookie, req, nil
+}
+
+// readString reads a uvarint length-prefixed string, returning what follows it
+func readString(b []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < size {
+		return "", nil, false
+	}
+	return string(b[n : n+int(size)]), b[n+int(size):], true
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenUDP starts a listener on a free localhost port, closed when the test ends
+func listenUDP(t *testing.T) *UDPListener {
+	t.Helper()
+	l, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// exchange sends pkt to the listener and returns its reply, or nil if none comes
+func exchange(t *testing.T, socket *net.UDPConn, pkt []byte) []byte {
+	t.Helper()
+	if _, err := socket.Write(pkt); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	socket.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	n, err := socket.Read(buf)
+	if err != nil {
+		return nil
+	}
+	return buf[:n]
+}
+
+func TestUDPConnectIsChallenged(t *testing.T) {
+	l := listenUDP(t)
+	socket, err := net.DialUDP("udp", nil, l.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+
+	const nonce = 42
+	connect := appendConnect(nil, nonce, nil, ConnectRequest{})
+
+	// An unpadded connect could be used to amplify spoofed traffic
+	if reply := exchange(t, socket, connect[:minConnectSize-1]); reply != nil {
+		t.Fatalf("unpadded connect answered with %x", reply)
+	}
+
+	reply := exchange(t, socket, connect)
+	if len(reply) != 9+cookieSize || reply[0] != packetChallenge {
+		t.Fatalf("connect answered with %x, want a challenge", reply)
+	}
+
+	// A made-up cookie is challenged again rather than accepted
+	forged := appendConnect(nil, nonce, make([]byte, cookieSize), ConnectRequest{})
+	if reply := exchange(t, socket, forged); len(reply) == 0 || reply[0] != packetChallenge {
+		t.Fatalf("forged cookie answered with %x, want a challenge", reply)
+	}
+
+	l.mu.Lock()
+	conns := len(l.conns)
+	l.mu.Unlock()
+	if conns != 0 {
+		t.Fatalf("listener holds %d connections before any cookie was echoed", conns)
+	}
+
+	echoed := appendConnect(nil, nonce, reply[9:], ConnectRequest{})
+	if reply := exchange(t, socket, echoed); len(reply) != 13 || reply[0] != packetAccept {
+		t.Fatalf("echoed cookie answered with %x, want an accept", reply)
+	}
+}
+
+func TestUDPDialExchangeAndClose(t *testing.T) {
+	l := listenUDP(t)
+
+	want := ConnectRequest{Binary: true, ResumeToken: "token", Room: "arena"}
+	client, err := DialUDP(l.Addr().String(), want, 2*time.Second)
+	if err != nil {
+		t.Fatalf("DialUDP: %v", err)
+	}
+	defer client.Close()
+
+	server, req, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if req != want {
+		t.Fatalf("Accept request = %+v, want %+v", req, want)
+	}
+
+	if err := client.WriteMessage(BinaryMessage, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	msgType, data, err := server.ReadMessage()
+	if err != nil || msgType != BinaryMessage || string(data) != "\x01\x02\x03" {
+		t.Fatalf("server read (%d, %x, %v)", msgType, data, err)
+	}
+
+	if err := server.WriteMessage(TextMessage, []byte(`{"type":"welcome"}`)); err != nil {
+		t.Fatal(err)
+	}
+	msgType, data, err = client.ReadMessage()
+	if err != nil || msgType != TextMessage || string(data) != `{"type":"welcome"}` {
+		t.Fatalf("client read (%d, %q, %v)", msgType, data, err)
+	}
+
+	server.SendClose(1008, "bye")
+	server.Close()
+
+	_, _, err = client.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 1008 || closeErr.Reason != "bye" {
+		t.Fatalf("client read after close: %v, want close 1008 bye", err)
+	}
+}
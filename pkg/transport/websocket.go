@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn adapts a gorilla WebSocket connection to Conn
+type wsConn struct {
+	ws        *websocket.Conn
+	writeWait time.Duration
+}
+
+// NewWebSocketConn wraps an upgraded WebSocket. Read limits and deadlines stay
+// with the caller; every write is bounded by writeWait.
+func NewWebSocketConn(ws *websocket.Conn, writeWait time.Duration) Conn {
+	return &wsConn{ws: ws, writeWait: writeWait}
+}
+
+func (c *wsConn) ReadMessage() (MessageType, []byte, error) {
+	msgType, data, err := c.ws.ReadMessage()
+	if err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return 0, nil, &CloseError{Code: closeErr.Code, Reason: closeErr.Text}
+		}
+		return 0, nil, err
+	}
+	return MessageType(msgType), data, nil
+}
+
+func (c *wsConn) WriteMessage(msgType MessageType, data []byte) error {
+	c.ws.SetWriteDeadline(time.Now().Add(c.writeWait))
+	return c.ws.WriteMessage(int(msgType), data)
+}
+
+func (c *wsConn) Ping() error {
+	c.ws.SetWriteDeadline(time.Now().Add(c.writeWait))
+	return c.ws.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (c *wsConn) SendClose(code int, reason string) error {
+	// Control frames may be written concurrently with WriteMessage
+	msg := websocket.FormatCloseMessage(code, truncateReason(reason))
+	return c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.writeWait))
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *wsConn) RemoteAddr() string {
+	return c.ws.RemoteAddr().String()
+}
@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialWebSocket connects a WebSocket to a test server and returns both ends wrapped as Conns
+func dialWebSocket(t *testing.T) (server, client Conn) {
+	t.Helper()
+
+	accepted := make(chan Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- NewWebSocketConn(ws, time.Second)
+	}))
+	t.Cleanup(srv.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	client = NewWebSocketConn(ws, time.Second)
+	server = <-accepted
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+func TestWebSocketConnMessageTypes(t *testing.T) {
+	server, client := dialWebSocket(t)
+
+	if err := client.WriteMessage(TextMessage, []byte(`{"type":"hello"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteMessage(BinaryMessage, []byte{0x01, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgType, data, err := server.ReadMessage(); err != nil || msgType != TextMessage || string(data) != `{"type":"hello"}` {
+		t.Fatalf("first read (%d, %q, %v), want the text message", msgType, data, err)
+	}
+	if msgType, data, err := server.ReadMessage(); err != nil || msgType != BinaryMessage || string(data) != "\x01\x02" {
+		t.Fatalf("second read (%d, %x, %v), want the binary message", msgType, data, err)
+	}
+	if err := server.Ping(); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	if server.RemoteAddr() == "" {
+		t.Error("RemoteAddr is empty")
+	}
+}
+
+func TestWebSocketConnCloseError(t *testing.T) {
+	server, client := dialWebSocket(t)
+
+	if err := server.SendClose(1008, "rate limit exceeded"); err != nil {
+		t.Fatalf("SendClose: %v", err)
+	}
+
+	var closeErr *CloseError
+	if _, _, err := client.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != 1008 || closeErr.Reason != "rate limit exceeded" {
+		t.Fatalf("read after close: %v, want close 1008 rate limit exceeded", err)
+	}
+}
+
+func TestWebSocketConnTruncatesCloseReason(t *testing.T) {
+	server, client := dialWebSocket(t)
+
+	// A frame over the WebSocket limit would be refused, leaving the peer without a close code
+	reason := strings.Repeat("ü", MaxCloseReason)
+	if err := server.SendClose(1002, reason); err != nil {
+		t.Fatalf("SendClose: %v", err)
+	}
+
+	var closeErr *CloseError
+	if _, _, err := client.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != 1002 {
+		t.Fatalf("read after close: %v, want close 1002", err)
+	}
+	if got := closeErr.Reason; len(got) > MaxCloseReason || !utf8.ValidString(got) || !strings.HasPrefix(reason, got) {
+		t.Errorf("reason of %d bytes %q, want a whole-character prefix of at most %d", len(got), got, MaxCloseReason)
+	}
+}
@@ -1,23 +1,145 @@
 package types
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-server-v1/pkg/transport"
+	"os"
+	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
-// Client represents a connected WebSocket client
+// Client represents a connected client on any transport
 type Client struct {
-	UUID     string          `json:"uuid"`
-	Conn     *websocket.Conn `json:"-"`
-	Send     chan []byte     `json:"-"`
-	Player   *Player         `json:"player"`
-	LastSeen time.Time       `json:"lastSeen"`
+	UUID     string         `json:"uuid"`
+	Conn     transport.Conn `json:"-"`
+	Send     chan []byte    `json:"-"`
+	Player   *Player        `json:"player"`
+	LastSeen time.Time      `json:"lastSeen"`
+
+	// Snapshot holds the newest snapshot the WritePump has not sent yet. Snapshots are
+	// conflated rather than queued, since each one supersedes the last.
+	Snapshot chan []byte `json:"-"`
+
+	// Ping holds a time sync ping for the WritePump, which stamps it as it writes it
+	Ping chan PingMessage `json:"-"`
+
+	// RTT is the smoothed round-trip time measured by time-sync pings, zero until measured.
+	// Only the run loop updates it.
+	RTT time.Duration `json:"rtt"`
+
+	// Encoding is the wire format negotiated for hot messages
+	Encoding string `json:"encoding"`
+
+	// Bandwidth is the snapshot budget in bytes per second agreed in the handshake, 0 for unlimited
+	Bandwidth int `json:"bandwidth"`
+
+	// SnapshotRate is how many snapshots per second the client gets, 0 for the server's rate.
+	// After registration only the run loop changes it.
+	SnapshotRate int `json:"snapshotRate"`
+
+	// Features are the optional protocol features agreed in the handshake
+	Features map[string]bool `json:"-"`
+
+	// Close code and reason sent once the hub closes Send; zero sends a bare close
+	CloseCode   int    `json:"-"`
+	CloseReason string `json:"-"`
+
+	// the last ping the WritePump wrote and when
+	pingMu      sync.Mutex
+	pingID      uint32
+	pingWritten time.Time
+}
+
+// Close codes the server sends when it ends a connection (RFC 6455)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseTryAgainLater   = 1013
+)
+
+// QueueSnapshot offers a snapshot to the WritePump, replacing one it has not sent yet.
+// It reports whether a snapshot was replaced. Only the hub's run loop may call it.
+func (c *Client) QueueSnapshot(data []byte) (conflated bool) {
+	select {
+	case c.Snapshot <- data:
+		return false
+	default:
+	}
+
+	// The slot is full; with a single producer, emptying it makes room
+	select {
+	case <-c.Snapshot:
+		conflated = true
+	default:
+	}
+	c.Snapshot <- data
+	return conflated
+}
+
+// MarkPingWritten records that the WritePump wrote ping id at the given time
+func (c *Client) MarkPingWritten(id uint32, at time.Time) {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	c.pingID = id
+	c.pingWritten = at
+}
+
+// PingWrittenAt returns when ping id was written, or false if it was not the last one written
+func (c *Client) PingWrittenAt(id uint32) (time.Time, bool) {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	if c.pingWritten.IsZero() || c.pingID != id {
+		return time.Time{}, false
+	}
+	return c.pingWritten, true
+}
+
+// ServerTime expresses t as the seconds-since-epoch float used on the wire
+func ServerTime(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// HasFeature reports whether the client negotiated an optional protocol feature
+func (c *Client) HasFeature(name string) bool {
+	return c.Features[name]
 }
 
+// Protocol versions; a client speaking any version in this range is accepted
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Optional protocol features a client can ask for in its hello
+const (
+	FeatureDeltaSnapshots = "deltaSnapshots" // snapshots may be deltas against acknowledged ones
+	FeatureInterestEvents = "interestEvents" // interestChanged notifications
+	FeatureTimeSync       = "timeSync"       // periodic server pings the client answers with pongs
+	FeatureReliableEvents = "reliableEvents" // gameplay events are sequenced, acknowledged and replayed
+)
+
+// ServerFeatures lists every optional feature this server supports
+var ServerFeatures = []string{
+	FeatureDeltaSnapshots,
+	FeatureInterestEvents,
+	FeatureTimeSync,
+	FeatureReliableEvents,
+}
+
+// Wire encodings a client can negotiate
+const (
+	EncodingJSON   = "json"
+	EncodingBinary = "binary"
+)
+
 // Player represents a game player with position and state
 type Player struct {
 	ID         string    `json:"id"`
+	NetID      uint32    `json:"netId"` // short numeric ID used by the binary protocol
 	PosX       float64   `json:"posX"`
 	PosY       float64   `json:"posY"`
 	MoveX      float64   `json:"moveX"`
@@ -28,10 +150,27 @@ type Player struct {
 	Health     int       `json:"health"`
 	MaxHealth  int       `json:"maxHealth"`
 	IsAlive    bool      `json:"isAlive"`
+
+	// Equipped weapon
+	Weapon       string    `json:"weapon"`
+	Ammo         int       `json:"ammo"`
+	Reloading    bool      `json:"reloading"`
+	NextFireAt   time.Time `json:"-"`
+	ReloadDoneAt time.Time `json:"-"`
+
+	// Client-side prediction reconciliation
+	LastProcessedInput int64 `json:"lastProcessedInput"` // SequenceID of the last applied input
+
+	// Respawn and spawn protection
+	Invulnerable   bool      `json:"invulnerable"`
+	DiedAt         time.Time `json:"-"`
+	SpawnedAt      time.Time `json:"-"`
+	ProtectedUntil time.Time `json:"-"`
 }
 
 type Projectile struct {
 	ID        string        `json:"id"`      // unique identifier
+	NetID     uint32        `json:"netId"`   // short numeric ID used by the binary protocol
 	OwnerID   string        `json:"ownerId"` // player who fired it
 	PosX      float64       `json:"posX"`
 	PosY      float64       `json:"posY"`
@@ -41,20 +180,41 @@ type Projectile struct {
 	Lifetime  time.Duration `json:"lifetime"` // remaining lifetime
 	Radius    float64       `json:"radius"`   // hitbox radius
 	Damage    int           `json:"damage"`   // how much damage it deals
+
+	// Rewind is how far behind the server the shooter's view was, used for lag compensation
+	Rewind time.Duration `json:"-"`
 }
 
 // Message types for client-server communication
 type MessageType string
 
 const (
-	PlayerInputMsg  MessageType = "playerInput"
-	PlayerStateMsg  MessageType = "playerState"
-	PlayerIDMsg     MessageType = "playerId"
-	PlayerJoinedMsg MessageType = "playerJoined"
-	PlayerLeftMsg   MessageType = "playerLeft"
-	GameStateMsg    MessageType = "gameState"
-	ChatMsg         MessageType = "chat"
-	ErrorMsg        MessageType = "error"
+	PlayerInputMsg     MessageType = "playerInput"
+	PlayerStateMsg     MessageType = "playerState"
+	PlayerIDMsg        MessageType = "playerId"
+	PlayerJoinedMsg    MessageType = "playerJoined"
+	PlayerLeftMsg      MessageType = "playerLeft"
+	GameStateMsg       MessageType = "gameState"
+	GameStateDeltaMsg  MessageType = "gameStateDelta"
+	SnapshotAckMsg     MessageType = "snapshotAck"
+	InterestChangedMsg MessageType = "interestChanged"
+	ShootMsg           MessageType = "shoot"
+	ReloadMsg          MessageType = "reload"
+	SwitchWeaponMsg    MessageType = "switchWeapon"
+	ChatMsg            MessageType = "chat"
+	ErrorMsg           MessageType = "error"
+	PlayerDamagedMsg   MessageType = "playerDamaged"
+	PlayerKilledMsg    MessageType = "playerKilled"
+	PlayerRespawnMsg   MessageType = "playerRespawned"
+	HelloMsg           MessageType = "hello"
+	WelcomeMsg         MessageType = "welcome"
+	TimeSyncMsg        MessageType = "timeSync"
+	TimeSyncReplyMsg   MessageType = "timeSyncReply"
+	PingMsg            MessageType = "ping"
+	PongMsg            MessageType = "pong"
+	EventMsg           MessageType = "event"
+	EventAckMsg        MessageType = "eventAck"
+	SnapshotRateMsg    MessageType = "snapshotRate"
 )
 
 // BaseMessage is the common wrapper for all messages
@@ -62,16 +222,107 @@ type BaseMessage struct {
 	Type string `json:"type"`
 }
 
-// ProjectileMessage is sent by the client when firing
-type ProjectileMessage struct {
-	Type         string  `json:"type"`
-	PlayerID     string  `json:"playerId"`
-	PosX         float64 `json:"posX"`
-	PosY         float64 `json:"posY"`
-	DirX         float64 `json:"dirX"`
-	DirY         float64 `json:"dirY"`
-	Speed        float64 `json:"speed"`
-	ProjectileID string  `json:"projectileId"`
+// HelloMessage is the first message a client sends on a new connection
+type HelloMessage struct {
+	Type            string   `json:"type"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Encodings       []string `json:"encodings"` // in order of preference; empty keeps the transport's choice
+	Features        []string `json:"features"`
+	ResumeToken     string   `json:"resumeToken"`  // optional, reclaims a player after a dropped connection
+	MaxBandwidth    int      `json:"maxBandwidth"` // optional snapshot budget in bytes per second, for slow links
+	SnapshotRate    int      `json:"snapshotRate"` // optional, snapshots per second below the server's rate
+}
+
+// WelcomeMessage answers a hello with what the server agreed to
+type WelcomeMessage struct {
+	Type            string      `json:"type"`
+	ProtocolVersion int         `json:"protocolVersion"`
+	Encoding        string      `json:"encoding"`
+	Features        []string    `json:"features"`
+	MaxBandwidth    int         `json:"maxBandwidth"` // snapshot budget in bytes per second, 0 for unlimited
+	TickRate        int         `json:"tickRate"`
+	TickInterval    float64     `json:"tickInterval"` // seconds between ticks
+	SnapshotRate    int         `json:"snapshotRate"` // snapshots per second the client will get
+	WorldBounds     WorldBounds `json:"worldBounds"`
+	ServerTime      float64     `json:"serverTime"`
+	Weapons         []string    `json:"weapons"` // names accepted by switchWeapon
+}
+
+// SnapshotRateMessage asks for a different snapshot rate after the handshake. The
+// server answers with the same message carrying the rate it applied.
+type SnapshotRateMessage struct {
+	Type string `json:"type"`
+	Rate int    `json:"rate"` // snapshots per second, 0 for the server's rate
+}
+
+// EventMessage carries one gameplay event to a client with the reliableEvents feature.
+// Seq counts up by one per event for the client's player and carries on across a
+// session resume, so the client can ignore events it has already applied.
+type EventMessage struct {
+	Type  string          `json:"type"`
+	Seq   uint64          `json:"seq"`
+	Event json.RawMessage `json:"event"`
+}
+
+// EventAckMessage acknowledges every event up to and including Seq
+type EventAckMessage struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+}
+
+// TimeSyncMessage is sent by a client to sample the server clock
+type TimeSyncMessage struct {
+	Type       string  `json:"type"`
+	ClientTime float64 `json:"clientTime"` // client clock when sent, echoed in the reply
+}
+
+// TimeSyncReplyMessage answers a time sync request. With t0 = ClientTime and t1 the
+// client clock on receipt, the client estimates rtt = t1 - t0 and its clock offset
+// as ServerTime + rtt/2 - t1.
+type TimeSyncReplyMessage struct {
+	Type       string  `json:"type"`
+	ClientTime float64 `json:"clientTime"`
+	ServerTime float64 `json:"serverTime"`
+	RTT        float64 `json:"rtt"` // the server's smoothed estimate in seconds, 0 until measured
+}
+
+// PingMessage is sent periodically to clients with the timeSync feature to measure their RTT
+type PingMessage struct {
+	Type       string  `json:"type"`
+	ID         uint32  `json:"id"`
+	ServerTime float64 `json:"serverTime"`
+	RTT        float64 `json:"rtt"` // the current estimate in seconds, 0 until measured
+}
+
+// PongMessage answers a ping as soon as the client receives it
+type PongMessage struct {
+	Type string `json:"type"`
+	ID   uint32 `json:"id"`
+}
+
+// ShootMessage is sent by the client when firing; the server decides everything but the aim
+type ShootMessage struct {
+	Type       string  `json:"type"`
+	DirX       float64 `json:"dirX"`
+	DirY       float64 `json:"dirY"`
+	SequenceID int64   `json:"sequenceId"`
+
+	// Timestamp is the server time, in seconds, of the world state the shooter was
+	// looking at when firing; zero lets the server estimate it from the RTT
+	Timestamp float64 `json:"timestamp,omitempty"`
+}
+
+// ReloadMessage is sent by the client to reload the equipped weapon
+type ReloadMessage struct {
+	Type string `json:"type"`
+}
+
+// SwitchWeaponMessage is sent by the client to equip another of the server's weapons,
+// listed in the welcome. The new weapon comes with a full magazine but cannot fire
+// until it has been drawn, which takes its reload time.
+type SwitchWeaponMessage struct {
+	Type   string `json:"type"`
+	Weapon string `json:"weapon"`
 }
 
 // PlayerInputMessage represents input from client
@@ -102,12 +353,18 @@ type PlayerStateMessage struct {
 type PlayerIDMessage struct {
 	Type     string `json:"type"`
 	PlayerID string `json:"playerId"`
+	NetID    uint32 `json:"netId"`
+
+	// ResumeToken lets a new connection reclaim this player after a disconnect
+	ResumeToken string `json:"resumeToken"`
+	Resumed     bool   `json:"resumed"` // true when an existing player was reclaimed
 }
 
 // PlayerJoinedMessage broadcast when a player joins
 type PlayerJoinedMessage struct {
 	Type     string  `json:"type"`
 	PlayerID string  `json:"playerId"`
+	NetID    uint32  `json:"netId"`
 	PosX     float64 `json:"posX"`
 	PosY     float64 `json:"posY"`
 }
@@ -116,19 +373,167 @@ type PlayerJoinedMessage struct {
 type PlayerLeftMessage struct {
 	Type     string `json:"type"`
 	PlayerID string `json:"playerId"`
+	NetID    uint32 `json:"netId"`
+}
+
+// PlayerSnapshot is the replicated subset of a player's state. The server tick it was
+// simulated at is the snapshot's tick: a client's own player is sent every snapshot,
+// so it reconciles LastProcessedInput against that tick.
+type PlayerSnapshot struct {
+	ID                 string  `json:"id"`
+	NetID              uint32  `json:"netId"`
+	PosX               float64 `json:"posX"`
+	PosY               float64 `json:"posY"`
+	MoveX              float64 `json:"moveX"`
+	MoveY              float64 `json:"moveY"`
+	FacingLeft         bool    `json:"facingLeft"`
+	Health             int     `json:"health"`
+	IsAlive            bool    `json:"isAlive"`
+	Invulnerable       bool    `json:"invulnerable"`
+	Weapon             string  `json:"weapon"`
+	Ammo               int     `json:"ammo"`
+	Reloading          bool    `json:"reloading"`
+	LastProcessedInput int64   `json:"lastProcessedInput"`
+}
+
+// ProjectileSnapshot is the replicated subset of a projectile's state
+type ProjectileSnapshot struct {
+	ID         string  `json:"id"`
+	NetID      uint32  `json:"netId"`
+	OwnerID    string  `json:"ownerId"`
+	OwnerNetID uint32  `json:"-"` // binary protocol only
+	PosX       float64 `json:"posX"`
+	PosY       float64 `json:"posY"`
+	VelX       float64 `json:"velX"`
+	VelY       float64 `json:"velY"`
+	Radius     float64 `json:"radius"`
+}
+
+// PlayerDelta carries only the player fields that changed since the baseline.
+// Entities new to the client have every field set.
+type PlayerDelta struct {
+	NetID              *uint32  `json:"netId,omitempty"`
+	PosX               *float64 `json:"posX,omitempty"`
+	PosY               *float64 `json:"posY,omitempty"`
+	MoveX              *float64 `json:"moveX,omitempty"`
+	MoveY              *float64 `json:"moveY,omitempty"`
+	FacingLeft         *bool    `json:"facingLeft,omitempty"`
+	Health             *int     `json:"health,omitempty"`
+	IsAlive            *bool    `json:"isAlive,omitempty"`
+	Invulnerable       *bool    `json:"invulnerable,omitempty"`
+	Weapon             *string  `json:"weapon,omitempty"`
+	Ammo               *int     `json:"ammo,omitempty"`
+	Reloading          *bool    `json:"reloading,omitempty"`
+	LastProcessedInput *int64   `json:"lastProcessedInput,omitempty"`
+}
+
+// ProjectileDelta carries only the projectile fields that changed since the baseline.
+// Entities new to the client have every field set.
+type ProjectileDelta struct {
+	NetID   *uint32  `json:"netId,omitempty"`
+	OwnerID *string  `json:"ownerId,omitempty"`
+	PosX    *float64 `json:"posX,omitempty"`
+	PosY    *float64 `json:"posY,omitempty"`
+	VelX    *float64 `json:"velX,omitempty"`
+	VelY    *float64 `json:"velY,omitempty"`
+	Radius  *float64 `json:"radius,omitempty"`
 }
 
 // GameStateMessage contains full game state
 type GameStateMessage struct {
-	Type      string             `json:"type"`
-	Players   map[string]*Player `json:"players"`
-	Timestamp float64            `json:"timestamp"`
+	Type        string                         `json:"type"`
+	Players     map[string]*PlayerSnapshot     `json:"players"`
+	Projectiles map[string]*ProjectileSnapshot `json:"projectiles"`
+	Timestamp   float64                        `json:"timestamp"`
+
+	// Interpolation metadata
+	Tick         uint64  `json:"tick"`         // server tick the snapshot was taken at
+	TickInterval float64 `json:"tickInterval"` // seconds between ticks
+	ServerTime   float64 `json:"serverTime"`   // server clock when the snapshot was sent
+}
+
+// GameStateDeltaMessage describes the game state relative to a snapshot the client acknowledged
+type GameStateDeltaMessage struct {
+	Type               string                      `json:"type"`
+	Tick               uint64                      `json:"tick"`
+	BaseTick           uint64                      `json:"baseTick"`
+	TickInterval       float64                     `json:"tickInterval"`
+	ServerTime         float64                     `json:"serverTime"`
+	Timestamp          float64                     `json:"timestamp"`
+	Players            map[string]*PlayerDelta     `json:"players,omitempty"`
+	RemovedPlayers     []string                    `json:"removedPlayers,omitempty"`
+	Projectiles        map[string]*ProjectileDelta `json:"projectiles,omitempty"`
+	RemovedProjectiles []string                    `json:"removedProjectiles,omitempty"`
 }
 
-// ChatMessage for player communication
+// Entity kinds used in interest notifications
+const (
+	EntityKindPlayer     = "player"
+	EntityKindProjectile = "projectile"
+)
+
+// EntityRef identifies a replicated entity
+type EntityRef struct {
+	ID    string `json:"id"`
+	NetID uint32 `json:"netId"`
+	Kind  string `json:"kind"`
+}
+
+// InterestChangedMessage tells a client which entities entered or left its area of interest
+type InterestChangedMessage struct {
+	Type    string      `json:"type"`
+	Tick    uint64      `json:"tick"`
+	Entered []EntityRef `json:"entered,omitempty"`
+	Left    []EntityRef `json:"left,omitempty"`
+}
+
+// SnapshotAckMessage is sent by the client for each snapshot it has applied
+type SnapshotAckMessage struct {
+	Type string `json:"type"`
+	Tick uint64 `json:"tick"`
+}
+
+// PlayerDamagedMessage broadcast when a projectile hits a player. The attacker's
+// NetID is zero if they already left.
+type PlayerDamagedMessage struct {
+	Type            string `json:"type"`
+	PlayerID        string `json:"playerId"`
+	NetID           uint32 `json:"netId"`
+	AttackerID      string `json:"attackerId"`
+	AttackerNetID   uint32 `json:"attackerNetId"`
+	ProjectileID    string `json:"projectileId"`
+	ProjectileNetID uint32 `json:"projectileNetId"`
+	Damage          int    `json:"damage"`
+	Health          int    `json:"health"`
+}
+
+// PlayerKilledMessage broadcast when a player's health reaches zero. The killer's
+// NetID is zero if they already left.
+type PlayerKilledMessage struct {
+	Type        string `json:"type"`
+	PlayerID    string `json:"playerId"`
+	NetID       uint32 `json:"netId"`
+	KillerID    string `json:"killerId"`
+	KillerNetID uint32 `json:"killerNetId"`
+}
+
+// PlayerRespawnedMessage broadcast when a dead player re-enters the game
+type PlayerRespawnedMessage struct {
+	Type         string  `json:"type"`
+	PlayerID     string  `json:"playerId"`
+	NetID        uint32  `json:"netId"`
+	PosX         float64 `json:"posX"`
+	PosY         float64 `json:"posY"`
+	Health       int     `json:"health"`
+	ProtectedFor float64 `json:"protectedFor"` // seconds of spawn protection
+}
+
+// ChatMessage for player communication. Clients send only the message; the server
+// fills in who said it.
 type ChatMessage struct {
 	Type      string  `json:"type"`
 	PlayerID  string  `json:"playerId"`
+	NetID     uint32  `json:"netId"`
 	Message   string  `json:"message"`
 	Timestamp float64 `json:"timestamp"`
 }
@@ -140,6 +545,13 @@ type ErrorMessage struct {
 	Message string `json:"message"`
 }
 
+// Error codes carried by ErrorMessage
+const (
+	ErrorCodeBadRequest         = 400
+	ErrorCodeUnsupportedVersion = 426
+	ErrorCodeRateLimited        = 429
+)
+
 // ClientAction represents actions that can be performed on clients
 type ClientAction struct {
 	Type   string
@@ -153,7 +565,156 @@ type GameConfig struct {
 	TickInterval time.Duration `json:"tickInterval"`
 	MoveSpeed    float64       `json:"moveSpeed"`
 	WorldBounds  WorldBounds   `json:"worldBounds"`
-	MaxPlayers   int           `json:"maxPlayers"`
+	MaxPlayers   int           `json:"maxPlayers"` // per room, counting players held for resume
+	PlayerRadius float64       `json:"playerRadius"`
+
+	// SnapshotRate is how many snapshots per second are sent, so the simulation can run
+	// faster than clients are updated; 0 or anything above TickRate sends one every tick
+	SnapshotRate int `json:"snapshotRate"`
+
+	// SpatialCellSize is the cell edge length of the collision and proximity grid
+	SpatialCellSize float64 `json:"spatialCellSize"`
+
+	// MapFile is an optional JSON map with obstacles; its bounds and spawn points override these
+	MapFile string `json:"mapFile"`
+
+	// Spawning when no map file provides spawn points
+	SpawnPoints     []SpawnPoint  `json:"spawnPoints"`
+	RespawnDelay    time.Duration `json:"respawnDelay"`
+	SpawnProtection time.Duration `json:"spawnProtection"`
+
+	// Lag compensation
+	MaxRewind          time.Duration `json:"maxRewind"`
+	InterpolationDelay time.Duration `json:"interpolationDelay"`
+
+	// Weapons available on the server, keyed by name
+	Weapons       map[string]WeaponConfig `json:"weapons"`
+	DefaultWeapon string                  `json:"defaultWeapon"`
+
+	// InterestRadius limits snapshots to entities this close to the client's player; 0 sends everything
+	InterestRadius float64 `json:"interestRadius"`
+
+	// SnapshotHistory is how many ticks old a client's acknowledged baseline may be
+	// before the server falls back to a full snapshot
+	SnapshotHistory int `json:"snapshotHistory"`
+
+	// InputRepeatTicks is how many ticks the last input is repeated when none arrives
+	InputRepeatTicks int `json:"inputRepeatTicks"`
+
+	// ReconnectGrace is how long a disconnected player stays in the world awaiting resume
+	ReconnectGrace time.Duration `json:"reconnectGrace"`
+
+	// RateLimits caps each connection per message type; the "default" entry covers the rest
+	RateLimits map[string]RateLimit `json:"rateLimits"`
+
+	// A connection that exceeds its limits MaxRateViolations times within
+	// RateViolationWindow is disconnected; 0 only drops the excess messages
+	MaxRateViolations   int           `json:"maxRateViolations"`
+	RateViolationWindow time.Duration `json:"rateViolationWindow"`
+
+	// TimeSyncInterval is how often clients with the timeSync feature are pinged
+	TimeSyncInterval time.Duration `json:"timeSyncInterval"`
+
+	// Reliable events are resent every EventResendInterval until acknowledged. A client
+	// with more than MaxPendingEvents unacknowledged is disconnected and the oldest dropped.
+	EventResendInterval time.Duration `json:"eventResendInterval"`
+	MaxPendingEvents    int           `json:"maxPendingEvents"`
+
+	// MaxChatLength caps chat messages, in characters
+	MaxChatLength int `json:"maxChatLength"`
+
+	// MaxBandwidth caps snapshot traffic per client in bytes per second; 0 is unlimited.
+	// Clients may ask for less in their hello.
+	MaxBandwidth int `json:"maxBandwidth"`
+
+	// MaxClientLag is how long a client may keep falling behind, with each snapshot
+	// replacing one it never received, before it is disconnected; 0 never disconnects
+	MaxClientLag time.Duration `json:"maxClientLag"`
+}
+
+// RateLimit is a token bucket refilling Rate messages per second, holding up to Burst
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// WeaponConfig defines how a weapon fires and reloads
+type WeaponConfig struct {
+	FireRate        float64       `json:"fireRate"` // shots per second
+	ProjectileSpeed float64       `json:"projectileSpeed"`
+	Damage          int           `json:"damage"`
+	Radius          float64       `json:"radius"`   // projectile hitbox radius
+	Lifetime        time.Duration `json:"lifetime"` // projectile lifetime
+	Spread          float64       `json:"spread"`   // cone angle in radians
+	MagazineSize    int           `json:"magazineSize"`
+	ReloadTime      time.Duration `json:"reloadTime"`
+}
+
+// weaponConfigJSON is how a WeaponConfig is written in config files, with its
+// durations as strings such as "1.5s"
+type weaponConfigJSON struct {
+	FireRate        float64 `json:"fireRate"`
+	ProjectileSpeed float64 `json:"projectileSpeed"`
+	Damage          int     `json:"damage"`
+	Radius          float64 `json:"radius"`
+	Lifetime        string  `json:"lifetime"`
+	Spread          float64 `json:"spread"`
+	MagazineSize    int     `json:"magazineSize"`
+	ReloadTime      string  `json:"reloadTime"`
+}
+
+// MarshalJSON writes the weapon's durations as duration strings
+func (w WeaponConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(weaponConfigJSON{
+		FireRate:        w.FireRate,
+		ProjectileSpeed: w.ProjectileSpeed,
+		Damage:          w.Damage,
+		Radius:          w.Radius,
+		Lifetime:        w.Lifetime.String(),
+		Spread:          w.Spread,
+		MagazineSize:    w.MagazineSize,
+		ReloadTime:      w.ReloadTime.String(),
+	})
+}
+
+// UnmarshalJSON reads a weapon whose durations are duration strings; omitted
+// durations are zero
+func (w *WeaponConfig) UnmarshalJSON(data []byte) error {
+	var raw weaponConfigJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var lifetime, reload time.Duration
+	var err error
+	if raw.Lifetime != "" {
+		if lifetime, err = time.ParseDuration(raw.Lifetime); err != nil {
+			return fmt.Errorf("weapon lifetime: %w", err)
+		}
+	}
+	if raw.ReloadTime != "" {
+		if reload, err = time.ParseDuration(raw.ReloadTime); err != nil {
+			return fmt.Errorf("weapon reloadTime: %w", err)
+		}
+	}
+
+	*w = WeaponConfig{
+		FireRate:        raw.FireRate,
+		ProjectileSpeed: raw.ProjectileSpeed,
+		Damage:          raw.Damage,
+		Radius:          raw.Radius,
+		Lifetime:        lifetime,
+		Spread:          raw.Spread,
+		MagazineSize:    raw.MagazineSize,
+		ReloadTime:      reload,
+	}
+	return nil
+}
+
+// SpawnPoint is a location where players can enter the world
+type SpawnPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 // WorldBounds defines the game world boundaries
@@ -174,6 +735,40 @@ const (
 	DefaultMinX         = -50.0
 	DefaultMinY         = -50.0
 	DefaultPlayerHealth = 100
+	DefaultPlayerRadius = 0.5
+
+	DefaultSpatialCellSize = 4.0
+
+	// Simulation
+	DefaultInputRepeatTicks = 3
+	DefaultSnapshotHistory  = 32
+	DefaultInterestRadius   = 40.0
+	DefaultReconnectGrace   = 30 * time.Second
+	DefaultTimeSyncInterval = 2 * time.Second
+
+	// Reliable events and chat
+	DefaultEventResendInterval = time.Second
+	DefaultMaxPendingEvents    = 256
+	DefaultMaxChatLength       = 100
+
+	// Slow clients
+	DefaultMaxClientLag = 5 * time.Second
+
+	// Flood protection
+	DefaultRateLimitKey        = "default"
+	DefaultMaxRateViolations   = 100
+	DefaultRateViolationWindow = 10 * time.Second
+
+	// Respawning
+	DefaultRespawnDelay    = 3 * time.Second
+	DefaultSpawnProtection = 2 * time.Second
+
+	// Lag compensation
+	DefaultMaxRewind          = 200 * time.Millisecond
+	DefaultInterpolationDelay = 100 * time.Millisecond
+
+	// Weapons
+	DefaultWeapon = "pistol"
 
 	// Connection timeouts
 	WriteWait      = 10 * time.Second
@@ -181,6 +776,32 @@ const (
 	PingPeriod     = (PongWait * 9) / 10
 	MaxMessageSize = 512
 
+	// HandshakeTimeout is how long a new connection has to send its hello
+	HandshakeTimeout = 5 * time.Second
+
+	// ShutdownTimeout bounds how long the server waits for connections to drain
+	ShutdownTimeout = 10 * time.Second
+
+	// Rooms; the default room serves connections that name none and is never collected
+	DefaultRoomID  = "default"
+	MaxRooms       = 100
+	EmptyRoomTTL   = time.Minute
+	RoomGCInterval = 10 * time.Second
+
+	// MaxTickRate bounds the simulation rate of any config
+	MaxTickRate = 128
+
+	// Limits on the overrides of rooms created at runtime
+	MinRoomMoveSpeed       = 0.1
+	MaxRoomMoveSpeed       = 50.0
+	MinRoomCellSize        = 1.0
+	MaxRoomCellSize        = 64.0
+	MaxRoomInterestRadius  = 500.0
+	MaxRoomRewind          = time.Second
+	MaxRoomRespawnDelay    = time.Minute
+	MaxRoomSpawnProtection = 30 * time.Second
+	MaxRoomReconnectGrace  = 5 * time.Minute
+
 	// Client timeouts
 	ClientTimeout = 30 * time.Second
 )
@@ -197,10 +818,209 @@ func GetDefaultConfig() *GameConfig {
 			MinX: DefaultMinX,
 			MinY: DefaultMinY,
 		},
-		MaxPlayers: DefaultMaxPlayers,
+		MaxPlayers:   DefaultMaxPlayers,
+		PlayerRadius: DefaultPlayerRadius,
+
+		SpatialCellSize: DefaultSpatialCellSize,
+		SpawnPoints: []SpawnPoint{
+			{X: 0, Y: 0},
+			{X: -40, Y: -40},
+			{X: 40, Y: -40},
+			{X: -40, Y: 40},
+			{X: 40, Y: 40},
+		},
+		RespawnDelay:    DefaultRespawnDelay,
+		SpawnProtection: DefaultSpawnProtection,
+
+		MaxRewind:          DefaultMaxRewind,
+		InterpolationDelay: DefaultInterpolationDelay,
+		InputRepeatTicks:   DefaultInputRepeatTicks,
+		SnapshotHistory:    DefaultSnapshotHistory,
+		InterestRadius:     DefaultInterestRadius,
+		ReconnectGrace:     DefaultReconnectGrace,
+		TimeSyncInterval:   DefaultTimeSyncInterval,
+
+		EventResendInterval: DefaultEventResendInterval,
+		MaxPendingEvents:    DefaultMaxPendingEvents,
+		MaxChatLength:       DefaultMaxChatLength,
+		MaxClientLag:        DefaultMaxClientLag,
+
+		// Inputs and acks arrive once per client frame, so allow a little over 60 Hz
+		RateLimits: map[string]RateLimit{
+			string(PlayerInputMsg):  {Rate: 90, Burst: 30},
+			string(SnapshotAckMsg):  {Rate: 90, Burst: 30},
+			string(ShootMsg):        {Rate: 30, Burst: 10},
+			string(ReloadMsg):       {Rate: 2, Burst: 4},
+			string(SwitchWeaponMsg): {Rate: 2, Burst: 4},
+			string(EventAckMsg):     {Rate: 60, Burst: 30},
+			string(ChatMsg):         {Rate: 1, Burst: 5},
+			DefaultRateLimitKey:     {Rate: 5, Burst: 10},
+		},
+		MaxRateViolations:   DefaultMaxRateViolations,
+		RateViolationWindow: DefaultRateViolationWindow,
+
+		Weapons: map[string]WeaponConfig{
+			"pistol": {
+				FireRate:        4,
+				ProjectileSpeed: 20,
+				Damage:          10,
+				Radius:          0.25,
+				Lifetime:        2 * time.Second,
+				Spread:          0.02,
+				MagazineSize:    12,
+				ReloadTime:      1500 * time.Millisecond,
+			},
+			"rifle": {
+				FireRate:        10,
+				ProjectileSpeed: 30,
+				Damage:          8,
+				Radius:          0.2,
+				Lifetime:        1500 * time.Millisecond,
+				Spread:          0.08,
+				MagazineSize:    30,
+				ReloadTime:      2 * time.Second,
+			},
+		},
+		DefaultWeapon: DefaultWeapon,
 	}
 }
 
+// MaxSnapshotRate is the snapshot rate clients get unless they ask for less
+func (c *GameConfig) MaxSnapshotRate() int {
+	if c.SnapshotRate <= 0 || c.SnapshotRate > c.TickRate {
+		return c.TickRate
+	}
+	return c.SnapshotRate
+}
+
+// LoadGameConfig reads a JSON config file, using defaults for any field it omits
+func LoadGameConfig(path string) (*GameConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	config := GetDefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// RoomOverrides are the config fields a room created at runtime may change, each
+// within limits. Every other field, notably MapFile, stays as the server was configured.
+// Durations are strings such as "150ms".
+type RoomOverrides struct {
+	TickRate        *int     `json:"tickRate,omitempty"`
+	SnapshotRate    *int     `json:"snapshotRate,omitempty"`
+	MaxPlayers      *int     `json:"maxPlayers,omitempty"` // at most the server's maxPlayers
+	MoveSpeed       *float64 `json:"moveSpeed,omitempty"`
+	SpatialCellSize *float64 `json:"spatialCellSize,omitempty"`
+	InterestRadius  *float64 `json:"interestRadius,omitempty"`
+	MaxRewind       string   `json:"maxRewind,omitempty"`
+	RespawnDelay    string   `json:"respawnDelay,omitempty"`
+	SpawnProtection string   `json:"spawnProtection,omitempty"`
+	ReconnectGrace  string   `json:"reconnectGrace,omitempty"`
+}
+
+// WithOverrides returns a copy of the config with the room overrides applied on top
+func (c *GameConfig) WithOverrides(o *RoomOverrides) (*GameConfig, error) {
+	base, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	config := &GameConfig{}
+	if err := json.Unmarshal(base, config); err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return config, nil
+	}
+
+	errs := []error{
+		overrideInt(&config.TickRate, o.TickRate, "tickRate", 1, MaxTickRate),
+		overrideInt(&config.MaxPlayers, o.MaxPlayers, "maxPlayers", 1, c.MaxPlayers),
+		overrideFloat(&config.MoveSpeed, o.MoveSpeed, "moveSpeed", MinRoomMoveSpeed, MaxRoomMoveSpeed),
+		overrideFloat(&config.SpatialCellSize, o.SpatialCellSize, "spatialCellSize", MinRoomCellSize, MaxRoomCellSize),
+		overrideFloat(&config.InterestRadius, o.InterestRadius, "interestRadius", 0, MaxRoomInterestRadius),
+		overrideDuration(&config.MaxRewind, o.MaxRewind, "maxRewind", 0, MaxRoomRewind),
+		overrideDuration(&config.RespawnDelay, o.RespawnDelay, "respawnDelay", 0, MaxRoomRespawnDelay),
+		overrideDuration(&config.SpawnProtection, o.SpawnProtection, "spawnProtection", 0, MaxRoomSpawnProtection),
+		overrideDuration(&config.ReconnectGrace, o.ReconnectGrace, "reconnectGrace", 0, MaxRoomReconnectGrace),
+	}
+	// The snapshot rate is bounded by the tick rate, so it goes after it
+	errs = append(errs, overrideInt(&config.SnapshotRate, o.SnapshotRate, "snapshotRate", 0, config.TickRate))
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// overrideInt sets *dst to *v if v is present and within [lo, hi]
+func overrideInt(dst *int, v *int, name string, lo, hi int) error {
+	if v == nil {
+		return nil
+	}
+	if *v < lo || *v > hi {
+		return fmt.Errorf("%s must be between %d and %d", name, lo, hi)
+	}
+	*dst = *v
+	return nil
+}
+
+// overrideFloat sets *dst to *v if v is present and within [lo, hi]
+func overrideFloat(dst *float64, v *float64, name string, lo, hi float64) error {
+	if v == nil {
+		return nil
+	}
+	if !(*v >= lo && *v <= hi) {
+		return fmt.Errorf("%s must be between %g and %g", name, lo, hi)
+	}
+	*dst = *v
+	return nil
+}
+
+// overrideDuration sets *dst to the duration s if it is present and within [lo, hi]
+func overrideDuration(dst *time.Duration, s string, name string, lo, hi time.Duration) error {
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if d < lo || d > hi {
+		return fmt.Errorf("%s must be between %v and %v", name, lo, hi)
+	}
+	*dst = d
+	return nil
+}
+
+// validate checks a config assembled from JSON and derives TickInterval from TickRate
+func (c *GameConfig) validate() error {
+	if c.TickRate <= 0 || c.TickRate > MaxTickRate {
+		return fmt.Errorf("tickRate must be between 1 and %d", MaxTickRate)
+	}
+	c.TickInterval = time.Second / time.Duration(c.TickRate)
+
+	if c.MaxPlayers <= 0 {
+		return errors.New("maxPlayers must be positive")
+	}
+
+	if _, ok := c.Weapons[c.DefaultWeapon]; !ok {
+		return fmt.Errorf("default weapon %q is not defined", c.DefaultWeapon)
+	}
+	return nil
+}
+
 // NewPlayer creates a new player with default values
 func NewPlayer(id string) *Player {
 	return &Player{
@@ -239,3 +1059,8 @@ func (wb *WorldBounds) ClampPosition(x, y float64) (float64, float64) {
 
 	return x, y
 }
+
+// Contains reports whether a position lies within world bounds
+func (wb *WorldBounds) Contains(x, y float64) bool {
+	return x >= wb.MinX && x <= wb.MaxX && y >= wb.MinY && y <= wb.MaxY
+}